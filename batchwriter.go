@@ -0,0 +1,149 @@
+package redisgo
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// BatchWriter 通过管道批量发送写命令，适用于大量写入的场景。
+// 累积的命令数量达到 flushEvery 或距上次发送超过 flushInterval 时自动发送，整个过程只占用一个连接。
+// 命令的执行结果被丢弃，每批命令中第一个执行失败的错误由发送该批命令的 Send 、 Flush 或 Close 返回，定时发送时的错误在下一次发送命令时返回。
+// 连接出错后，之后的调用都返回该错误。
+type BatchWriter struct {
+	c          *Cacher
+	flushEvery int
+
+	mu      sync.Mutex
+	conn    redis.Conn
+	pending int
+	err     error // 连接错误，出现后不再发送命令
+	lastErr error // 定时发送时命令执行失败的错误，在下一次发送命令时返回
+	closed  bool
+
+	done chan struct{}
+}
+
+var errBatchWriterClosed = errors.New("redisgo: batch writer closed")
+
+// NewBatchWriter 创建批量写入器，使用完毕后必须调用 Close 发送剩余的命令并归还连接。
+// flushEvery 小于等于0时不按数量发送，flushInterval 小于等于0时不定时发送。
+// Example:
+//
+// ```golang
+// w := c.NewBatchWriter(500, time.Second)
+// defer w.Close()
+// err := w.Send("SET", "zengate_name", "corel")
+// ```
+func (c *Cacher) NewBatchWriter(flushEvery int, flushInterval time.Duration) *BatchWriter {
+	w := &BatchWriter{
+		c:          c,
+		flushEvery: flushEvery,
+		conn:       c.track(c.pool.Get()),
+		done:       make(chan struct{}),
+	}
+	if flushInterval > 0 {
+		go w.flushPeriodically(flushInterval)
+	}
+	return w
+}
+
+// Send 将命令加入待发送的队列，参数与 Do 相同，键名不会自动加上前缀
+func (w *BatchWriter) Send(commandName string, args ...interface{}) error {
+	if err := w.c.checkCommand(commandName); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errBatchWriterClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.conn.Send(commandName, args...); err != nil {
+		w.err = err
+		return err
+	}
+	w.pending++
+	if w.flushEvery > 0 && w.pending >= w.flushEvery {
+		return w.flush()
+	}
+	return nil
+}
+
+// Flush 立即发送队列中的所有命令并等待执行完成
+func (w *BatchWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errBatchWriterClosed
+	}
+	return w.flush()
+}
+
+// Close 发送剩余的命令，停止定时发送并归还连接
+func (w *BatchWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	err := w.flush()
+	w.conn.Close()
+	return err
+}
+
+// flush 发送命令并读取结果，返回第一个错误，调用前必须持有锁
+func (w *BatchWriter) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	cmdErr := w.lastErr
+	w.lastErr = nil
+	if w.pending == 0 {
+		return cmdErr
+	}
+	if err := w.conn.Flush(); err != nil {
+		w.err = err
+		return err
+	}
+	for ; w.pending > 0; w.pending-- {
+		_, err := w.conn.Receive()
+		if _, ok := err.(redis.Error); ok {
+			// 单个命令执行失败不影响其他命令
+			if cmdErr == nil {
+				cmdErr = wrapError(err)
+			}
+			continue
+		}
+		if err != nil {
+			w.err = err
+			return err
+		}
+	}
+	return cmdErr
+}
+
+func (w *BatchWriter) flushPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if !w.closed && w.pending > 0 {
+				if err := w.flush(); err != nil && w.err == nil {
+					w.lastErr = err
+				}
+			}
+			w.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package redisgo
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrCircuitOpen 熔断器打开期间， Do 直接返回该错误，不再建立连接或发送命令
+var ErrCircuitOpen = errors.New("redisgo: circuit breaker is open")
+
+// breaker 熔断器。在 window 时间内连续失败 threshold 次后打开，打开期间拒绝所有命令；
+// 经过 cooldown 后进入半开状态，只允许一个命令通过作为探测，成功则关闭，失败则再次打开。
+type breaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	mu           sync.Mutex
+	failures     int       // 连续失败的次数
+	firstFailure time.Time // 本轮连续失败中第一次失败的时间
+	openUntil    time.Time // 熔断器打开的截止时间，为零值时表示关闭
+	probing      bool      // 半开状态下是否已有探测命令在执行
+}
+
+func newBreaker(opts Options) *breaker {
+	if opts.BreakerThreshold <= 0 {
+		return nil
+	}
+	if opts.BreakerCooldown <= 0 {
+		opts.BreakerCooldown = 5
+	}
+	return &breaker{
+		threshold: opts.BreakerThreshold,
+		window:    time.Duration(opts.BreakerWindow) * time.Second,
+		cooldown:  time.Duration(opts.BreakerCooldown) * time.Second,
+	}
+}
+
+// allow 返回是否允许执行命令
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record 记录命令的执行结果。服务器返回的错误（例如 WRONGTYPE）说明连接正常，视为成功
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var redisErr redis.Error
+	if err == nil || errors.As(err, &redisErr) {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		b.probing = false
+		return
+	}
+	now := time.Now()
+	if b.probing {
+		b.probing = false
+		b.openUntil = now.Add(b.cooldown)
+		return
+	}
+	if b.failures == 0 || (b.window > 0 && now.Sub(b.firstFailure) > b.window) {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.failures = 0
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
@@ -0,0 +1,209 @@
+package redisgo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// clusterSlots 集群的哈希槽数量
+const clusterSlots = 16384
+
+// clusterMaxRedirects 单个命令最多跟随 MOVED 和 ASK 重定向的次数
+const clusterMaxRedirects = 5
+
+// cluster 根据键所在的哈希槽将命令发送到对应的集群节点
+type cluster struct {
+	opts  Options
+	seeds []string
+
+	mu    sync.RWMutex
+	pools map[string]*redis.Pool // 节点地址到连接池的映射
+	slots [clusterSlots]string   // 哈希槽到主节点地址的映射
+}
+
+func newCluster(opts Options) *cluster {
+	cl := &cluster{
+		opts:  opts,
+		seeds: opts.ClusterAddrs,
+		pools: make(map[string]*redis.Pool),
+	}
+	// 启动时加载失败也没有关系，命令会发送到种子节点并根据 MOVED 重定向
+	cl.refresh()
+	return cl
+}
+
+// refresh 通过 CLUSTER SLOTS 命令重新加载哈希槽的分布
+func (cl *cluster) refresh() error {
+	var lastErr error
+	for _, addr := range cl.seeds {
+		conn := cl.pool(addr).Get()
+		values, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		cl.mu.Lock()
+		defer cl.mu.Unlock()
+		for _, value := range values {
+			slot, ok := value.([]interface{})
+			if !ok || len(slot) < 3 {
+				return fmt.Errorf("redisgo: unexpected cluster slots reply, got type %T", value)
+			}
+			start, err := redis.Int(slot[0], nil)
+			if err != nil {
+				return err
+			}
+			end, err := redis.Int(slot[1], nil)
+			if err != nil {
+				return err
+			}
+			node, err := redis.Values(slot[2], nil)
+			if err != nil || len(node) < 2 {
+				return fmt.Errorf("redisgo: unexpected cluster node, got type %T", slot[2])
+			}
+			host, err := redis.String(node[0], nil)
+			if err != nil {
+				return err
+			}
+			port, err := redis.Int(node[1], nil)
+			if err != nil {
+				return err
+			}
+			addr := net.JoinHostPort(host, strconv.Itoa(port))
+			for i := start; i <= end && i < clusterSlots; i++ {
+				cl.slots[i] = addr
+			}
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// pool 返回连接到 addr 节点的连接池，不存在时创建
+func (cl *cluster) pool(addr string) *redis.Pool {
+	cl.mu.RLock()
+	pool, ok := cl.pools[addr]
+	cl.mu.RUnlock()
+	if ok {
+		return pool
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if pool, ok = cl.pools[addr]; !ok {
+		pool = newPool(cl.opts, staticAddr(addr))
+		cl.pools[addr] = pool
+	}
+	return pool
+}
+
+// addr 返回负责 key 所在哈希槽的节点地址，未知时返回第一个种子节点
+func (cl *cluster) addr(key string) string {
+	cl.mu.RLock()
+	addr := cl.slots[keySlot(key)]
+	cl.mu.RUnlock()
+	if addr == "" {
+		addr = cl.seeds[0]
+	}
+	return addr
+}
+
+// poolForKey 返回 key 所在节点的连接池
+func (cl *cluster) poolForKey(key string) *redis.Pool {
+	return cl.pool(cl.addr(key))
+}
+
+// do 以第一个参数作为键，在其所在的节点上执行命令，并处理 MOVED 和 ASK 重定向
+func (cl *cluster) do(commandName string, args ...interface{}) (interface{}, error) {
+	return cl.doWith(doCommand, commandName, args...)
+}
+
+// doWith 与 do 相同，但通过 exec 在选定的连接上执行命令
+func (cl *cluster) doWith(exec commandFunc, commandName string, args ...interface{}) (interface{}, error) {
+	addr := cl.seeds[0]
+	if len(args) > 0 {
+		if key, ok := args[0].(string); ok {
+			addr = cl.addr(key)
+		}
+	}
+	asking := false
+	for i := 0; i <= clusterMaxRedirects; i++ {
+		conn := cl.pool(addr).Get()
+		if asking {
+			conn.Send("ASKING")
+		}
+		reply, err := exec(conn, commandName, args...)
+		conn.Close()
+
+		redisErr, ok := err.(redis.Error)
+		if !ok {
+			return reply, err
+		}
+		fields := strings.Fields(string(redisErr))
+		if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+			return reply, err
+		}
+		addr = fields[2]
+		asking = fields[0] == "ASK"
+		if fields[0] == "MOVED" {
+			// 哈希槽已迁移到其他节点，更新映射
+			slot, err := strconv.Atoi(fields[1])
+			if err == nil && slot >= 0 && slot < clusterSlots {
+				cl.mu.Lock()
+				cl.slots[slot] = addr
+				cl.mu.Unlock()
+			}
+		}
+	}
+	return nil, errClusterTooManyRedirects
+}
+
+// close 关闭所有节点的连接池
+func (cl *cluster) close() {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for _, pool := range cl.pools {
+		pool.Close()
+	}
+}
+
+var errClusterTooManyRedirects = errors.New("redisgo: too many cluster redirects")
+
+// HashTag 返回以 {tag} 开头的键名，例如 HashTag("user:42", ":profile") 返回 {user:42}:profile 。
+// 集群模式下只使用 {} 中的内容计算哈希槽，所以使用同一个 tag 的键都在同一个节点上，可以在同一个事务、管道或多键命令中使用。
+// 键名前缀会加在 {tag} 的前面，不影响哈希槽的计算，但前缀中不能包含 { 和 } 。
+func HashTag(tag string, key string) string {
+	return "{" + tag + "}" + key
+}
+
+// keySlot 返回键所在的哈希槽。键中包含 {tag} 时，只使用 tag 计算哈希槽
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16([]byte(key)) % clusterSlots)
+}
+
+// crc16 使用redis集群规范中的CRC16（XMODEM）算法计算校验值
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
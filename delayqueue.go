@@ -0,0 +1,110 @@
+package redisgo
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// DelayQueue 基于有序集合实现的延时队列，score 为任务到期的时间（毫秒时间戳）。
+// 多个消费者可以同时调用 Poll ，每个任务只会被其中一个取出。
+type DelayQueue struct {
+	c   *Cacher
+	key string
+}
+
+// NewDelayQueue 创建延时队列
+// Example:
+//
+// ```golang
+// q := c.NewDelayQueue("notify")
+// err := q.Schedule("job-1", time.Now().Add(time.Minute))
+// jobs, err := q.Poll(time.Now(), 10)
+// ```
+func (c *Cacher) NewDelayQueue(key string) *DelayQueue {
+	return &DelayQueue{
+		c:   c,
+		key: key,
+	}
+}
+
+// Schedule 添加任务，任务在 runAt 之后才能被 Poll 取出。相同的任务重复添加时更新到期时间。
+func (q *DelayQueue) Schedule(payload interface{}, runAt time.Time) error {
+	value, err := q.c.encode(payload)
+	if err != nil {
+		return err
+	}
+	_, err = q.c.Do("ZADD", q.c.getKey(q.key), toMillis(runAt), value)
+	return err
+}
+
+var delayQueuePollScript = redis.NewScript(-1, `
+local jobs = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #jobs > 0 then
+	redis.call('ZREM', KEYS[1], unpack(jobs))
+end
+return jobs
+`)
+
+// Poll 取出并删除最多 count 个在 now 之前到期的任务，按到期时间排序
+func (q *DelayQueue) Poll(now time.Time, count int) ([]string, error) {
+	return redis.Strings(q.c.evalScript(delayQueuePollScript, []string{q.key}, toMillis(now), count))
+}
+
+// Job 通过 ScheduleJob 添加的任务
+type Job struct {
+	ID      string // 添加任务时生成的随机ID
+	Payload string // 序列化后的任务内容，非基本类型可以使用 Decode 反序列化
+}
+
+// ScheduleJob 添加任务并返回随机生成的任务ID，任务在 runAt 之后才能被 PollDueJobs 取出。
+// 与 Schedule 不同，内容相同的任务多次添加时是不同的任务，各自被取出一次。
+// 同一个队列应只使用 ScheduleJob 和 PollDueJobs ，或只使用 Schedule 和 Poll 。
+func (q *DelayQueue) ScheduleJob(payload interface{}, runAt time.Time) (id string, err error) {
+	value, err := q.c.encode(payload)
+	if err != nil {
+		return "", err
+	}
+	if id, err = randomToken(); err != nil {
+		return "", err
+	}
+	member := id + ":" + string(encodedReply(value))
+	if _, err := q.c.Do("ZADD", q.c.getKey(q.key), toMillis(runAt), member); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// PollDueJobs 原子地取出并删除最多 max 个在 now 之前到期的任务，按到期时间排序。
+// 不是通过 ScheduleJob 添加的任务（例如通过 Schedule 添加到同一个队列）也会返回，其 ID 为空， Payload 为完整的内容。
+func (q *DelayQueue) PollDueJobs(now time.Time, max int) ([]Job, error) {
+	members, err := q.Poll(now, max)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]Job, len(members))
+	for i, member := range members {
+		jobs[i] = parseJob(member)
+	}
+	return jobs, nil
+}
+
+// jobIDLen ScheduleJob 生成的任务ID的长度，与 randomToken 相同
+const jobIDLen = 32
+
+// parseJob 将 ScheduleJob 保存的 "ID:内容" 拆分为 Job ，格式不符时 ID 为空
+func parseJob(member string) Job {
+	if len(member) <= jobIDLen || member[jobIDLen] != ':' {
+		return Job{Payload: member}
+	}
+	if _, err := hex.DecodeString(member[:jobIDLen]); err != nil {
+		return Job{Payload: member}
+	}
+	return Job{ID: member[:jobIDLen], Payload: member[jobIDLen+1:]}
+}
+
+// toMillis 返回毫秒时间戳
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
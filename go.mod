@@ -1,3 +1,5 @@
 module github.com/aiscrm/redisgo
 
+go 1.18
+
 require github.com/gomodule/redigo v2.0.0+incompatible
@@ -1,9 +1,18 @@
 package redisgo
 
 import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+
 	"github.com/gomodule/redigo/redis"
 )
 
+// ErrNil indicates that a reply value is nil, e.g. the key does not exist.
+// It is the same value as redis.ErrNil.
+var ErrNil = redis.ErrNil
+
 // Int is a helper that converts a command reply to an integer
 func Int(reply interface{}, err error) (int, error) {
 	return redis.Int(reply, err)
@@ -23,3 +32,95 @@ func String(reply interface{}, err error) (string, error) {
 func Bool(reply interface{}, err error) (bool, error) {
 	return redis.Bool(reply, err)
 }
+
+// IsNil reports whether err indicates a nil reply, e.g. a missing key.
+func IsNil(err error) bool {
+	return errors.Is(err, ErrNil)
+}
+
+// IsTimeout reports whether err is caused by a command or network timeout.
+func IsTimeout(err error) bool {
+	if errors.Is(err, ErrTimeout) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsConnError reports whether err is caused by the connection rather than by
+// the command, e.g. the server is unreachable, the connection was closed or
+// the pool is exhausted. Such errors are usually worth retrying.
+func IsConnError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, redis.ErrPoolExhausted)
+}
+
+// RedisError is an error reply returned by the Redis server, such as
+// "WRONGTYPE Operation against a key holding the wrong kind of value".
+// Use errors.As to get it from an error returned by this package.
+type RedisError struct {
+	err redis.Error
+}
+
+// Error implements the error interface.
+func (e *RedisError) Error() string {
+	return string(e.err)
+}
+
+// Unwrap returns the underlying redis.Error.
+func (e *RedisError) Unwrap() error {
+	return e.err
+}
+
+// Code returns the error code, the first word of the reply, e.g. WRONGTYPE.
+func (e *RedisError) Code() string {
+	code, _ := e.split()
+	return code
+}
+
+// Message returns the reply without the error code.
+func (e *RedisError) Message() string {
+	_, message := e.split()
+	return message
+}
+
+func (e *RedisError) split() (code, message string) {
+	s := string(e.err)
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// wrapError converts an error reply from the server to a *RedisError.
+func wrapError(err error) error {
+	if e, ok := err.(redis.Error); ok {
+		return &RedisError{err: e}
+	}
+	return err
+}
+
+// errorCode returns the code of the Redis error reply in err, or "" if err is
+// not an error reply.
+func errorCode(err error) string {
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		return (&RedisError{err: redisErr}).Code()
+	}
+	return ""
+}
+
+// IsWrongType reports whether err is a WRONGTYPE error reply, returned when a
+// command is used against a key holding the wrong kind of value.
+func IsWrongType(err error) bool {
+	return errorCode(err) == "WRONGTYPE"
+}
+
+// IsOOM reports whether err is an OOM error reply, returned when the server
+// has reached maxmemory and cannot evict keys.
+func IsOOM(err error) bool {
+	return errorCode(err) == "OOM"
+}
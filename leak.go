@@ -0,0 +1,43 @@
+package redisgo
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// trackedConn 记录借出位置的连接，持有时间超过阈值时输出警告，用于检测连接泄漏
+type trackedConn struct {
+	redis.Conn
+	timer *time.Timer
+}
+
+// track 启用连接泄漏检测时，包装借出的连接
+func (c *Cacher) track(conn redis.Conn) redis.Conn {
+	if c.leakThreshold <= 0 {
+		return conn
+	}
+	stack := debug.Stack()
+	threshold := c.leakThreshold
+	logger := c.logger
+	return &trackedConn{
+		Conn: conn,
+		timer: time.AfterFunc(threshold, func() {
+			logger.Printf("connection has been held for more than %s, possible leak, borrowed at:\n%s", threshold, stack)
+		}),
+	}
+}
+
+func (tc *trackedConn) Close() error {
+	tc.timer.Stop()
+	return tc.Conn.Close()
+}
+
+func (tc *trackedConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return redis.DoWithTimeout(tc.Conn, timeout, commandName, args...)
+}
+
+func (tc *trackedConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(tc.Conn, timeout)
+}
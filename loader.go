@@ -0,0 +1,107 @@
+package redisgo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// loadGroup 合并对同一个键的并发加载，同一时间每个键只有一个加载函数在执行，其他调用等待并共享其结果
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+type loadCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// do 执行 fn 并返回其结果，同一个键已有加载在执行时等待其完成
+func (g *loadGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*loadCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.value, call.err
+	}
+	call := &loadCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.value, call.err = fn()
+	call.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	return call.value, call.err
+}
+
+// GetOrLoadRefresh 获取键值并按照 Decode 的方式写入 dest ，键不存在时调用 load 加载并以 expire 秒的有效期保存。
+// 剩余生存时间少于 expire 的 refreshRatio 倍时（例如 0.2 表示最后20%的时间），在后台重新加载并更新键值，本次调用仍返回当前的值，
+// 避免热点键过期时大量请求同时等待加载。同一个进程内对同一个键的加载会被合并，同一时间只执行一次 load 。
+// 后台加载失败时只记录日志，键过期后由下一次调用重新加载。
+// Example:
+//
+// ```golang
+// var user User
+// err := c.GetOrLoadRefresh("user:1", &user, 600, 0.2, func() (interface{}, error) {
+// return db.GetUser(1)
+// })
+// ```
+func (c *Cacher) GetOrLoadRefresh(key string, dest interface{}, expire int64, refreshRatio float64, load func() (interface{}, error)) error {
+	reload := func() (interface{}, error) {
+		val, err := load()
+		if err != nil {
+			return nil, err
+		}
+		value, err := c.encode(val)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Set(key, value, expire); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	ttl, err := c.GetWithTTL(key, dest)
+	if err == nil {
+		if ttl >= 0 && float64(ttl) < float64(expire)*refreshRatio*float64(time.Second) {
+			go func() {
+				if _, err := c.loads.do(key, reload); err != nil {
+					c.logger.Printf("refresh %s: %v", key, err)
+				}
+			}()
+		}
+		return nil
+	}
+	if err != ErrNil {
+		return err
+	}
+	value, err := c.loads.do(key, reload)
+	if err != nil {
+		return err
+	}
+	return c.Decode(encodedReply(value), dest)
+}
+
+// encodedReply 将 encode 返回的值转换为与 GET 命令相同的回复
+func encodedReply(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return []byte(v)
+	case bool:
+		if v {
+			return []byte("1")
+		}
+		return []byte("0")
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,86 @@
+package redisgo
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// localCache 进程内的LRU缓存，用于 GetCached 的一级缓存
+type localCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type localEntry struct {
+	key      string
+	value    string
+	expireAt time.Time
+}
+
+func newLocalCache(size int) *localCache {
+	return &localCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get 获取未过期的缓存值，并将其移动到最近使用的位置
+func (lc *localCache) get(key string) (string, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	e, ok := lc.items[key]
+	if !ok {
+		return "", false
+	}
+	entry := e.Value.(*localEntry)
+	if time.Now().After(entry.expireAt) {
+		lc.removeElement(e)
+		return "", false
+	}
+	lc.ll.MoveToFront(e)
+	return entry.value, true
+}
+
+// set 保存缓存值，超过容量时淘汰最久未使用的值
+func (lc *localCache) set(key, value string, ttl time.Duration) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	expireAt := time.Now().Add(ttl)
+	if e, ok := lc.items[key]; ok {
+		entry := e.Value.(*localEntry)
+		entry.value = value
+		entry.expireAt = expireAt
+		lc.ll.MoveToFront(e)
+		return
+	}
+	lc.items[key] = lc.ll.PushFront(&localEntry{key: key, value: value, expireAt: expireAt})
+	for lc.ll.Len() > lc.size {
+		lc.removeElement(lc.ll.Back())
+	}
+}
+
+// del 删除缓存值
+func (lc *localCache) del(key string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if e, ok := lc.items[key]; ok {
+		lc.removeElement(e)
+	}
+}
+
+// clear 删除所有缓存值
+func (lc *localCache) clear() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.ll.Init()
+	lc.items = make(map[string]*list.Element)
+}
+
+func (lc *localCache) removeElement(e *list.Element) {
+	lc.ll.Remove(e)
+	delete(lc.items, e.Value.(*localEntry).key)
+}
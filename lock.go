@@ -0,0 +1,67 @@
+package redisgo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// TryLock 尝试获取分布式锁，不会阻塞。获取成功时 ok 为 true ，并返回用于释放的令牌。
+// expire 为锁的有效时长，单位为秒，持有者崩溃后锁在过期时自动释放。
+// Example:
+//
+// ```golang
+// token, ok, err := c.TryLock("order:1", 10)
+// // 获取成功后执行任务，完成后释放
+// err = c.Unlock("order:1", token)
+// ```
+func (c *Cacher) TryLock(key string, expire int64) (token string, ok bool, err error) {
+	token, err = randomToken()
+	if err != nil {
+		return "", false, err
+	}
+	reply, err := c.Do("SET", c.getKey(key), token, "EX", expire, "NX")
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	return token, true, nil
+}
+
+// LockWait 获取分布式锁，锁被其他持有者占用时每隔 pollInterval 重试一次，直到获取成功或 ctx 被取消。
+// ctx 被取消时返回 ctx.Err() 。pollInterval 必须大于0。其他参数与 TryLock 相同。
+func (c *Cacher) LockWait(ctx context.Context, key string, expire int64, pollInterval time.Duration) (string, error) {
+	if pollInterval <= 0 {
+		return "", fmt.Errorf("redisgo: pollInterval must be positive, got %s", pollInterval)
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		token, ok, err := c.TryLock(key, expire)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return token, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+var unlockScript = redis.NewScript(-1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Unlock 释放通过 TryLock 或 LockWait 获取的锁。锁已过期或已被其他持有者获取时不做任何操作。
+func (c *Cacher) Unlock(key string, token string) error {
+	_, err := c.evalScript(unlockScript, []string{key}, token)
+	return err
+}
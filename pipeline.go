@@ -0,0 +1,83 @@
+package redisgo
+
+import "github.com/gomodule/redigo/redis"
+
+// Pipeline 收集多个命令，在 Exec 时通过管道一次发送，只需要一次网络往返。
+// 与 BatchWriter 不同，Pipeline 返回每个命令各自的结果，适用于需要检查每个命令是否执行成功的批量操作。
+type Pipeline struct {
+	c        *Cacher
+	commands []pipelineCommand
+}
+
+type pipelineCommand struct {
+	name string
+	args []interface{}
+}
+
+// Result 管道中单个命令的执行结果
+type Result struct {
+	Value interface{} // 命令的回复，可以使用 String 、 Int 等工具方法转换
+	Err   error       // 命令执行失败时服务器返回的错误，例如 WRONGTYPE
+}
+
+// NewPipeline 创建管道。
+// Example:
+//
+// ```golang
+// p := c.NewPipeline()
+// p.Send("SET", "zengate_name", "corel")
+// p.Send("INCR", "zengate_count")
+// results, err := p.Exec()
+// ```
+func (c *Cacher) NewPipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Send 将命令加入管道，参数与 Do 相同，键名不会自动加上前缀
+func (p *Pipeline) Send(commandName string, args ...interface{}) {
+	p.commands = append(p.commands, pipelineCommand{name: commandName, args: args})
+}
+
+// Len 返回管道中等待发送的命令数量
+func (p *Pipeline) Len() int {
+	return len(p.commands)
+}
+
+// Exec 发送管道中的所有命令并清空管道，返回的结果与命令一一对应。
+// 管道中包含未通过 Options.AllowDangerousCommands 启用的命令时，不发送任何命令并返回 ErrDangerousCommandDisabled 。
+// 单个命令执行失败不影响其他命令，错误保存在对应结果的 Err 中；只有连接错误等导致无法读取结果时才返回 error 。
+func (p *Pipeline) Exec() ([]Result, error) {
+	commands := p.commands
+	p.commands = nil
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	for _, cmd := range commands {
+		if err := p.c.checkCommand(cmd.name); err != nil {
+			return nil, err
+		}
+	}
+	conn := p.c.track(p.c.pool.Get())
+	defer conn.Close()
+	for _, cmd := range commands {
+		if err := conn.Send(cmd.name, cmd.args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(commands))
+	for i := range results {
+		reply, err := conn.Receive()
+		if _, ok := err.(redis.Error); ok {
+			results[i].Err = wrapError(err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[i].Value = reply
+	}
+	return results, nil
+}
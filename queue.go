@@ -0,0 +1,44 @@
+package redisgo
+
+// Enqueue 将任务加入列表实现的队列，与 Dequeue 、 Ack 一起实现至少一次（at-least-once）消费的可靠队列。
+// Example:
+//
+// ```golang
+// err := c.Enqueue("jobs", job)
+// item, err := c.Dequeue("jobs", "jobs:processing")
+// // 处理完成后确认，消费者在确认前崩溃时，任务仍保留在 jobs:processing 中
+// err = c.Ack("jobs:processing", item)
+// ```
+func (c *Cacher) Enqueue(key string, item interface{}) error {
+	return c.LPush(key, item)
+}
+
+// Dequeue 取出队列 queue 中最早加入的任务，同时原子地将其放入处理中列表 processing ，队列为空时返回 ErrNil 。
+// 返回的是序列化后的值，非基本类型的任务可以使用 Decode 反序列化，确认时应传入原值。
+// 集群模式下两个键必须在同一个哈希槽中，参见 HashTag 。
+func (c *Cacher) Dequeue(queue, processing string) (string, error) {
+	return c.RPopLPush(queue, processing)
+}
+
+// Ack 确认任务已处理完成，将其从处理中列表 processing 中删除
+func (c *Cacher) Ack(processing string, item string) error {
+	_, err := c.Do("LREM", c.getKey(processing), 1, item)
+	return err
+}
+
+// Requeue 将处理中列表 processing 中的所有任务放回队列 queue ，返回放回的任务数量。
+// 用于消费者崩溃后恢复未确认的任务，一般在消费者启动时对自己的处理中列表调用。
+// 处理中列表不记录任务的取出时间，所以多个消费者共用同一个处理中列表时，不能判断其中的任务是否已经超时，应为每个消费者使用单独的处理中列表。
+func (c *Cacher) Requeue(processing, queue string) (int, error) {
+	count := 0
+	for {
+		_, err := c.Dequeue(processing, queue)
+		if err == ErrNil {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
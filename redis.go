@@ -4,11 +4,20 @@
 package redisgo
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -23,20 +32,87 @@ type Cacher struct {
 	prefix    string
 	marshal   func(v interface{}) ([]byte, error)
 	unmarshal func(data []byte, v interface{}) error
+
+	replicas *replicaPools
+	readOnly bool
+	cluster  *cluster
+
+	local             *localCache
+	invalidateChannel string
+	tracker           *tracker
+	allowDebug        bool
+	allowDangerous    bool
+
+	logger        *log.Logger
+	leakThreshold time.Duration
+	heartbeat     time.Duration
+
+	subscribeBuffer   int
+	subscribeOverflow string
+
+	breaker *breaker
+	loads   *loadGroup
+
+	commandHook func(commandName string, elapsed time.Duration, err error)
+	drainedAt   *int64
+
+	closing       chan struct{} // 调用 Shutdown 后被关闭，之后 Do 不再借出新连接
+	closeOnce     *sync.Once
+	subscriptions *int32 // 订阅占用的连接数， Shutdown 不等待这些连接归还
 }
 
 // Options redis配置参数
 type Options struct {
-	Network     string                                 // 通讯协议，默认为 tcp
-	Addr        string                                 // redis服务的地址，默认为 127.0.0.1:6379
+	Network     string                                 // 通讯协议， tcp 或 unix ，默认为 tcp 。redis与程序在同一台机器上时，使用 unix socket 可以减少TCP的开销
+	Addr        string                                 // redis服务的地址，默认为 127.0.0.1:6379 。Network 为 unix 时为socket文件的路径，例如 /var/run/redis/redis.sock
 	Password    string                                 // redis鉴权密码
 	Db          int                                    // 数据库
 	MaxActive   int                                    // 最大活动连接数，值为0时表示不限制
 	MaxIdle     int                                    // 最大空闲连接数
 	IdleTimeout int                                    // 空闲连接的超时时间，超过该时间则关闭连接。单位为秒。默认值是5分钟。值为0时表示不关闭空闲连接。此值应该总是大于redis服务的超时时间。
 	Prefix      string                                 // 键名前缀
-	Marshal     func(v interface{}) ([]byte, error)    // 数据序列化方法，默认使用json.Marshal序列化
-	Unmarshal   func(data []byte, v interface{}) error // 数据反序列化方法，默认使用json.Unmarshal序列化
+	ClientName  string                                 // 连接的名称，建立连接时通过 CLIENT SETNAME 设置，便于在 CLIENT LIST 中识别连接所属的服务。名称中不能包含空格
+	Marshal     func(v interface{}) ([]byte, error)    // 数据序列化方法，默认使用 MarshalFunc 序列化
+	Unmarshal   func(data []byte, v interface{}) error // 数据反序列化方法，默认使用 UnmarshalFunc 反序列化
+
+	DisableHTMLEscape bool // 使用默认的json序列化时，不将字符串中的 < 、 > 、 & 转义为 \u003c 等形式，此时总是使用标准库序列化，不使用 MarshalFunc 。设置了 Marshal 时忽略
+
+	SentinelAddrs    []string // 哨兵的地址列表。设置后忽略 Addr ，每次建立新连接时都会向哨兵查询主节点的当前地址，以便在故障转移后连接到新的主节点。建议同时设置 MaxConnLifetime ，使旧连接能被及时替换
+	MasterName       string   // 使用哨兵时，主节点的名称
+	SentinelPassword string   // 哨兵的密码，哨兵开启了 requirepass 时需要设置，与主节点的 Password 相互独立
+
+	ClusterAddrs []string // 集群节点的地址列表，只需要包含部分节点。设置后忽略 Addr 、 Db ，命令会根据第一个参数（键名）所在的哈希槽发送到对应的节点。涉及多个键的命令需要使用 {tag} 保证所有的键在同一个哈希槽
+
+	ReplicaAddrs []string // 从节点的地址列表，用于 DoReadOnly 分担读请求。从节点的通讯协议、密码、数据库等配置与主节点相同
+
+	BorrowTestInterval int // 从连接池取出连接时，只有空闲时间超过该值的连接才通过 PING 检查是否可用，可以减少频繁使用的连接池的网络往返。单位为秒。值为0时表示每次取出都检查
+
+	MaxConnLifetime int // 连接的最大存活时间，超过该时间的连接在从连接池取出时会被关闭并重新建立，可避免故障转移后继续使用旧连接。单位为秒。值为0时表示不限制。
+
+	SubscribeHeartbeat int    // 订阅连接的心跳间隔，单位为秒。订阅期间定期发送PING，超过两个间隔没有收到任何回复时认为连接已失效并重新连接，可以发现TCP连接仍然存在但数据已不再传输的情况。值为0时表示不发送心跳
+	SubscribeBuffer    int    // 订阅消息的缓冲区大小。大于0时，收到的消息先放入缓冲区，由一个协程按顺序调用 onMessage ，处理较慢时不会阻塞接收；值为0时每条消息在新的协程中处理
+	SubscribeOverflow  string // 缓冲区已满时的处理策略： block（默认）等待缓冲区有空位，会阻塞接收； drop-oldest 丢弃缓冲区中最早的消息； drop-newest 丢弃新收到的消息。丢弃的消息数量可以通过 Subscription.Dropped 获取
+
+	LocalCacheSize    int    // 进程内缓存（用于GetCached）的最大条目数，值为0时表示不启用进程内缓存
+	InvalidateChannel string // 用于在多个实例间通知进程内缓存失效的频道，默认为 redisgo:invalidate
+
+	TrackingCacheSize int // 客户端缓存（用于GetTracked）的最大条目数，通过 CLIENT TRACKING 在键被修改时自动失效，需要redis 6.0及以上版本。值为0时表示不启用。不支持集群模式
+
+	Logger        *log.Logger // 用于输出警告信息，默认输出到标准错误
+	LeakThreshold int         // 连接泄漏检测的阈值，SetWithTags 、 HMSet 等持有连接的方法借出的连接超过该时间未归还时，通过 Logger 输出借出位置的调用栈。单位为秒。值为0时表示不检测，不会带来额外开销
+
+	BreakerThreshold int // 熔断器的失败次数阈值。在 BreakerWindow 时间内连续失败（无法建立连接、网络错误等，不包括服务器返回的错误）达到该次数后， Do 直接返回 ErrCircuitOpen ，避免服务不可用时每个命令都等待连接超时。值为0时表示不启用熔断器
+	BreakerWindow    int // 统计连续失败次数的时间窗口，单位为秒。值为0时表示不限制
+	BreakerCooldown  int // 熔断器打开后，经过该时间才允许一个命令通过以探测服务是否恢复。单位为秒。默认值是5秒
+
+	AllowDangerousCommands bool // 是否允许执行 FLUSHALL 、 FLUSHDB 、 KEYS 等可能清空数据或阻塞服务的命令，包括 Flush 、 FlushAll 以及通过 Do 直接执行。默认不允许，避免误操作清空生产环境的数据
+
+	CommandHook       func(commandName string, elapsed time.Duration, err error) // 通过 Do 执行的每个命令完成后调用， elapsed 为获取连接及网络往返的时间，不包括序列化的时间。用于统计命令耗时，不应执行耗时的操作
+	SerializationHook func(op string, elapsed time.Duration)                     // 每次调用 Marshal 或 Unmarshal 后调用， op 为 marshal 或 unmarshal 。与 CommandHook 一起使用，可以区分序列化与命令执行的耗时。基本类型不经过序列化，不会调用
+
+	AllowDebug bool // 是否允许执行 DebugSleep 、 DebugObject 等DEBUG命令。DEBUG命令会阻塞服务，不要在生产环境中启用
+
+	drainedAt *int64 // 最后一次调用 DrainIdle 的时间，由 StartAndGC 设置，所有连接池共用
 }
 
 // New 根据配置参数创建redis工具实例
@@ -50,6 +126,10 @@ func New(options Options) (*Cacher, error) {
 func (c *Cacher) StartAndGC(options interface{}) error {
 	switch opts := options.(type) {
 	case Options:
+		if opts.TrackingCacheSize > 0 && len(opts.ClusterAddrs) > 0 {
+			// 失效通知只能重定向到同一个节点上的连接
+			return errors.New("redisgo: TrackingCacheSize is not supported in cluster mode")
+		}
 		if opts.Network == "" {
 			opts.Network = "tcp"
 		}
@@ -62,56 +142,423 @@ func (c *Cacher) StartAndGC(options interface{}) error {
 		if opts.IdleTimeout == 0 {
 			opts.IdleTimeout = 300
 		}
-		if opts.Marshal == nil {
-			c.marshal = json.Marshal
+		c.prefix = opts.Prefix
+		c.marshal = opts.Marshal
+		if c.marshal == nil {
+			c.marshal = defaultMarshal
+			if opts.DisableHTMLEscape {
+				c.marshal = marshalWithoutHTMLEscape
+			}
+		}
+		c.unmarshal = opts.Unmarshal
+		if c.unmarshal == nil {
+			c.unmarshal = defaultUnmarshal
+		}
+		if opts.SerializationHook != nil {
+			c.marshal, c.unmarshal = timeSerialization(c.marshal, c.unmarshal, opts.SerializationHook)
+		}
+		c.commandHook = opts.CommandHook
+		masterAddr := staticAddr(opts.Addr)
+		if len(opts.SentinelAddrs) > 0 {
+			masterAddr = func() (string, error) {
+				return sentinelMasterAddr(dialSentinel(opts.Network), opts.SentinelAddrs, opts.MasterName, opts.SentinelPassword)
+			}
+		}
+		opts.drainedAt = new(int64)
+		c.drainedAt = opts.drainedAt
+		c.cluster = nil
+		if len(opts.ClusterAddrs) > 0 {
+			opts.Db = 0
+			c.cluster = newCluster(opts)
+			c.pool = c.cluster.pool(opts.ClusterAddrs[0])
+		} else {
+			c.pool = newPool(opts, masterAddr)
 		}
-		if opts.Unmarshal == nil {
-			c.unmarshal = json.Unmarshal
+		c.replicas = &replicaPools{}
+		for _, addr := range opts.ReplicaAddrs {
+			c.replicas.pools = append(c.replicas.pools, newPool(opts, staticAddr(addr)))
 		}
-		pool := &redis.Pool{
-			MaxActive:   opts.MaxActive,
-			MaxIdle:     opts.MaxIdle,
-			IdleTimeout: time.Duration(opts.IdleTimeout) * time.Second,
+		c.allowDebug = opts.AllowDebug
+		c.allowDangerous = opts.AllowDangerousCommands
+		if opts.Logger == nil {
+			opts.Logger = log.New(os.Stderr, "redisgo: ", log.LstdFlags)
+		}
+		c.logger = opts.Logger
+		c.leakThreshold = time.Duration(opts.LeakThreshold) * time.Second
+		c.heartbeat = time.Duration(opts.SubscribeHeartbeat) * time.Second
+		c.subscribeBuffer = opts.SubscribeBuffer
+		c.subscribeOverflow = opts.SubscribeOverflow
+		c.breaker = newBreaker(opts)
+		c.loads = &loadGroup{}
+		c.closing = make(chan struct{})
+		c.closeOnce = &sync.Once{}
+		c.subscriptions = new(int32)
+		c.closePool()
 
-			Dial: func() (redis.Conn, error) {
-				conn, err := redis.Dial(opts.Network, opts.Addr)
-				if err != nil {
+		if opts.LocalCacheSize > 0 {
+			if opts.InvalidateChannel == "" {
+				opts.InvalidateChannel = "redisgo:invalidate"
+			}
+			c.local = newLocalCache(opts.LocalCacheSize)
+			c.invalidateChannel = opts.InvalidateChannel
+			go c.Subscribe(func(channel string, data []byte) error {
+				c.local.del(string(data))
+				return nil
+			}, c.invalidateChannel)
+		}
+		c.tracker = nil
+		if opts.TrackingCacheSize > 0 {
+			c.tracker = &tracker{cache: newLocalCache(opts.TrackingCacheSize)}
+			go c.runTracker()
+		}
+		return nil
+	default:
+		return errors.New("Unsupported options")
+	}
+}
+
+// timeSerialization 包装序列化方法，每次调用后通过 hook 报告耗时
+func timeSerialization(marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error, hook func(op string, elapsed time.Duration)) (func(v interface{}) ([]byte, error), func(data []byte, v interface{}) error) {
+	timedMarshal := func(v interface{}) ([]byte, error) {
+		start := time.Now()
+		defer func() {
+			hook("marshal", time.Since(start))
+		}()
+		return marshal(v)
+	}
+	timedUnmarshal := func(data []byte, v interface{}) error {
+		start := time.Now()
+		defer func() {
+			hook("unmarshal", time.Since(start))
+		}()
+		return unmarshal(data, v)
+	}
+	return timedMarshal, timedUnmarshal
+}
+
+// newPool 创建连接池，每次建立新连接时通过 addr 获取要连接的地址
+func newPool(opts Options, addr func() (string, error)) *redis.Pool {
+	return &redis.Pool{
+		MaxActive:   opts.MaxActive,
+		MaxIdle:     opts.MaxIdle,
+		IdleTimeout: time.Duration(opts.IdleTimeout) * time.Second,
+
+		MaxConnLifetime: time.Duration(opts.MaxConnLifetime) * time.Second,
+
+		Dial: func() (redis.Conn, error) {
+			address, err := addr()
+			if err != nil {
+				return nil, err
+			}
+			conn, err := redis.Dial(opts.Network, address)
+			if err != nil {
+				return nil, err
+			}
+			if opts.Password != "" {
+				if _, err := conn.Do("AUTH", opts.Password); err != nil {
+					conn.Close()
 					return nil, err
 				}
-				if opts.Password != "" {
-					if _, err := conn.Do("AUTH", opts.Password); err != nil {
-						conn.Close()
-						return nil, err
-					}
-				}
-				if _, err := conn.Do("SELECT", opts.Db); err != nil {
+			}
+			if _, err := conn.Do("SELECT", opts.Db); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			if opts.ClientName != "" {
+				if _, err := conn.Do("CLIENT", "SETNAME", opts.ClientName); err != nil {
 					conn.Close()
 					return nil, err
 				}
-				return conn, err
-			},
+			}
+			return conn, err
+		},
 
-			TestOnBorrow: func(conn redis.Conn, t time.Time) error {
-				_, err := conn.Do("PING")
-				return err
-			},
-		}
+		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
+			if opts.drainedAt != nil && t.UnixNano() < atomic.LoadInt64(opts.drainedAt) {
+				return errDrained
+			}
+			if time.Since(t) < time.Duration(opts.BorrowTestInterval)*time.Second {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+	}
+}
 
-		c.pool = pool
-		c.closePool()
-		return nil
-	default:
-		return errors.New("Unsupported options")
+// staticAddr 返回固定的地址
+func staticAddr(addr string) func() (string, error) {
+	return func() (string, error) {
+		return addr, nil
 	}
 }
 
 // Do 执行redis命令并返回结果。执行时从连接池获取连接并在执行完命令后关闭连接。
+// redis返回的错误回复会被转换为 *RedisError 。
+// 通过 ReadOnly 返回的实例会在从节点上执行命令。
+// 通过 Options.BreakerThreshold 启用熔断器后，熔断器打开期间返回 ErrCircuitOpen 。
+// FLUSHALL 、 FLUSHDB 、 KEYS 命令需要通过 Options.AllowDangerousCommands 启用。调用 Shutdown 后返回 ErrShutdown 。
 func (c *Cacher) Do(commandName string, args ...interface{}) (reply interface{}, err error) {
-	conn := c.pool.Get()
-	defer conn.Close()
+	return c.do(doCommand, c.readOnly, commandName, args...)
+}
+
+// commandFunc 在选定的连接上执行命令
+type commandFunc func(conn redis.Conn, commandName string, args ...interface{}) (interface{}, error)
+
+func doCommand(conn redis.Conn, commandName string, args ...interface{}) (interface{}, error) {
 	return conn.Do(commandName, args...)
 }
 
+// do 是 Do 、 DoTimeout 、 DoReadOnly 共用的执行流程：检查命令是否允许执行、是否已调用 Shutdown ，
+// 经过熔断器和 CommandHook ，并根据集群模式和 readOnly 选择节点，最后通过 exec 在选定的连接上执行命令
+func (c *Cacher) do(exec commandFunc, readOnly bool, commandName string, args ...interface{}) (reply interface{}, err error) {
+	if err := c.checkCommand(commandName); err != nil {
+		return nil, err
+	}
+	select {
+	case <-c.closing:
+		return nil, ErrShutdown
+	default:
+	}
+	if c.breaker != nil {
+		if !c.breaker.allow() {
+			return nil, ErrCircuitOpen
+		}
+		defer func() {
+			c.breaker.record(err)
+		}()
+	}
+	if c.commandHook != nil {
+		start := time.Now()
+		defer func() {
+			c.commandHook(commandName, time.Since(start), err)
+		}()
+	}
+	if c.cluster != nil {
+		reply, err = c.cluster.doWith(exec, commandName, args...)
+		return reply, wrapError(err)
+	}
+	pool := c.pool
+	if readOnly {
+		pool = c.replicaPool()
+	}
+	conn := pool.Get()
+	defer conn.Close()
+	reply, err = exec(conn, commandName, args...)
+	return reply, wrapError(err)
+}
+
+// ErrTimeout 命令执行超时时返回的错误，可以通过 errors.Is(err, ErrTimeout) 判断
+var ErrTimeout = errors.New("redisgo: command timed out")
+
+// DoTimeout 与 Do 相同，但等待回复的时间最多为 timeout ，超时时返回 ErrTimeout 。
+// 超时的连接会被关闭，不会放回连接池。
+func (c *Cacher) DoTimeout(timeout time.Duration, commandName string, args ...interface{}) (reply interface{}, err error) {
+	reply, err = c.do(func(conn redis.Conn, commandName string, args ...interface{}) (interface{}, error) {
+		return redis.DoWithTimeout(conn, timeout, commandName, args...)
+	}, c.readOnly, commandName, args...)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return nil, fmt.Errorf("%w: %s after %s", ErrTimeout, commandName, timeout)
+	}
+	return reply, err
+}
+
+// ErrShutdown 调用 Shutdown 后执行命令时返回该错误
+var ErrShutdown = errors.New("redisgo: client is shut down")
+
+// Shutdown 优雅地关闭连接池：之后 Do 及基于 Do 的方法不再借出新连接并返回 ErrShutdown ，
+// 等待已借出的连接全部归还（正在执行的命令完成）后关闭所有连接池。
+// ctx 到期时不再等待，直接关闭连接池并返回 ctx.Err() 。
+// 订阅占用的连接不在等待的范围内，应在调用前先调用 Subscription.Close 。
+func (c *Cacher) Shutdown(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+	})
+	defer c.closeAll()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if c.inUse() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// inUse 返回所有连接池中已借出未归还的连接数，不包括订阅占用的连接
+func (c *Cacher) inUse() int {
+	pools := append([]*redis.Pool{}, c.replicas.pools...)
+	if c.cluster != nil {
+		// 集群模式下 c.pool 是其中一个节点的连接池
+		c.cluster.mu.RLock()
+		for _, pool := range c.cluster.pools {
+			pools = append(pools, pool)
+		}
+		c.cluster.mu.RUnlock()
+	} else {
+		pools = append(pools, c.pool)
+	}
+	n := -int(atomic.LoadInt32(c.subscriptions))
+	for _, pool := range pools {
+		stats := pool.Stats()
+		n += stats.ActiveCount - stats.IdleCount
+	}
+	return n
+}
+
+// Warmup 预先建立 n 个连接并放入连接池，避免程序启动后的前几个请求因为建立连接而变慢。
+// n 超过最大空闲连接数或最大活动连接数时，只建立允许保留的数量。返回第一个建立连接时发生的错误。
+func (c *Cacher) Warmup(n int) error {
+	if n > c.pool.MaxIdle {
+		n = c.pool.MaxIdle
+	}
+	if c.pool.MaxActive > 0 && n > c.pool.MaxActive {
+		n = c.pool.MaxActive
+	}
+	conns := make([]redis.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		conn := c.pool.Get()
+		conns = append(conns, conn)
+		if err := conn.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DoReadOnly 与 Do 相同，但命令会按轮询的方式在 Options.ReplicaAddrs 配置的从节点上执行，用于分担主节点的读请求。
+// 没有配置从节点时在主节点上执行。只应用于执行读命令，从节点的数据可能稍微落后于主节点。
+func (c *Cacher) DoReadOnly(commandName string, args ...interface{}) (reply interface{}, err error) {
+	return c.do(doCommand, true, commandName, args...)
+}
+
+// getConn 获取用于执行 key 相关命令的连接，集群模式下返回 key 所在节点的连接。使用完毕后需要关闭连接。
+func (c *Cacher) getConn(key string) redis.Conn {
+	if c.cluster != nil {
+		return c.track(c.cluster.poolForKey(key).Get())
+	}
+	return c.track(c.pool.Get())
+}
+
+// ReadOnly 返回一个只读的实例，通过它执行的 GetString 、 HGetAll 等方法都会按轮询的方式在从节点上执行，用于读多写少的场景。
+// 只读实例与原实例共用连接池。没有配置 Options.ReplicaAddrs 时，等同于原实例。
+// Example:
+//
+// ```golang
+// name, err := c.ReadOnly().GetString("name")
+// ```
+func (c *Cacher) ReadOnly() *Cacher {
+	rc := *c
+	rc.readOnly = true
+	return &rc
+}
+
+// replicaPools 从节点的连接池
+type replicaPools struct {
+	pools []*redis.Pool
+	next  uint32 // 下一个执行读命令的从节点，用于轮询
+}
+
+// replicaPool 按轮询的方式返回从节点的连接池，没有配置从节点时返回主节点的连接池
+func (c *Cacher) replicaPool() *redis.Pool {
+	if len(c.replicas.pools) == 0 {
+		return c.pool
+	}
+	n := atomic.AddUint32(&c.replicas.next, 1)
+	return c.replicas.pools[int(n)%len(c.replicas.pools)]
+}
+
+// Pool 返回底层的连接池，用于直接调用 github.com/gomodule/redigo/redis 包中未封装的功能。
+// 通过 Pool().Get() 取得的连接使用完毕后，调用方必须负责调用连接的Close方法将其归还到连接池。
+func (c *Cacher) Pool() *redis.Pool {
+	return c.pool
+}
+
+var errDrained = errors.New("redisgo: connection drained")
+
+// DrainIdle 丢弃所有连接池（包括从节点和集群节点的连接池）中当前空闲的连接，之后借出连接时重新建立连接，连接池本身不关闭。
+// 空闲连接在下一次被借出时才关闭；调用时正在使用的连接不受影响，归还后可以继续使用。
+// 用于故障转移或网络拓扑变化后，避免继续使用连接到旧主节点的连接。
+func (c *Cacher) DrainIdle() {
+	atomic.StoreInt64(c.drainedAt, time.Now().UnixNano())
+}
+
+// Monitor 在一个专用的连接上执行 MONITOR 命令，通过返回的通道实时输出服务器收到的每个命令，用于调试。
+// 每次调用都会建立一个新的连接，不占用连接池，也不受 MaxActive 限制；ctx 被取消或连接出错时关闭连接和通道。
+// 执行 MONITOR 后连接不能再执行其他命令，所以不会放回连接池。MONITOR 会明显降低redis的性能，不要在生产环境中长时间使用。
+// Example:
+//
+// ```golang
+// ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// defer cancel()
+// lines, err := c.Monitor(ctx)
+// for line := range lines {
+// fmt.Println(line)
+// }
+// ```
+func (c *Cacher) Monitor(ctx context.Context) (<-chan string, error) {
+	conn, err := c.pool.Dial()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Do("MONITOR"); err != nil {
+		conn.Close()
+		return nil, wrapError(err)
+	}
+	lines := make(chan string)
+	done := make(chan struct{})
+	go func() {
+		// 关闭连接使下面的 Receive 返回
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+	go func() {
+		defer close(lines)
+		defer close(done)
+		for {
+			line, err := redis.String(conn.Receive())
+			if err != nil {
+				return
+			}
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return lines, nil
+}
+
+// WithConn 从连接池借出一个连接并传给 fn ，fn 返回后（包括发生panic时）自动将连接归还到连接池，返回 fn 的返回值。
+// 用于执行本包未封装的一系列命令。conn 在 fn 返回后不能再使用，也不能保存到 fn 之外；键名不会自动加上前缀。
+// Example:
+//
+// ```golang
+// err := c.WithConn(func(conn redis.Conn) error {
+// _, err := conn.Do("CLIENT", "SETNAME", "worker")
+// return err
+// })
+// ```
+func (c *Cacher) WithConn(fn func(conn redis.Conn) error) error {
+	conn := c.track(c.pool.Get())
+	defer conn.Close()
+	return fn(conn)
+}
+
 // Get 获取键值。一般不直接使用该值，而是配合下面的工具类方法获取具体类型的值，或者直接使用github.com/gomodule/redigo/redis包的工具方法。
 func (c *Cacher) Get(key string) (interface{}, error) {
 	return c.Do("GET", c.getKey(key))
@@ -137,25 +584,476 @@ func (c *Cacher) GetBool(key string) (bool, error) {
 	return Bool(c.Get(key))
 }
 
-// GetObject 获取非基本类型stuct的键值。在实现上，使用json的Marshal和Unmarshal做序列化存取。
+// GetStringOr 获取string类型的键值，键不存在或出错时返回默认值 def 。ErrNil 以外的错误通过 Options.Logger 输出。
+func (c *Cacher) GetStringOr(key, def string) string {
+	val, err := c.GetString(key)
+	if err != nil {
+		c.logUnlessNil(key, err)
+		return def
+	}
+	return val
+}
+
+// GetBytes 获取键值的原始字节，不经过反序列化，与 SetBytes 对应
+func (c *Cacher) GetBytes(key string) ([]byte, error) {
+	return redis.Bytes(c.Get(key))
+}
+
+// GetIntOr 获取int类型的键值，键不存在或出错时返回默认值 def 。ErrNil 以外的错误通过 Options.Logger 输出。
+func (c *Cacher) GetIntOr(key string, def int) int {
+	val, err := c.GetInt(key)
+	if err != nil {
+		c.logUnlessNil(key, err)
+		return def
+	}
+	return val
+}
+
+// logUnlessNil 输出读取键值时 ErrNil 以外的错误
+func (c *Cacher) logUnlessNil(key string, err error) {
+	if !IsNil(err) {
+		c.logger.Printf("get %s failed, using the default value: %v", key, err)
+	}
+}
+
+// GetObject 获取键值并写入 val ，与 Set 对应：val 为 *string 、 *int 等基本类型的指针时直接转换， struct 等其他类型使用json反序列化。
+// 因此通过 Set 保存的 int 、 string 、 struct 都可以使用对应类型的指针读取，规则与 Decode 相同。
 func (c *Cacher) GetObject(key string, val interface{}) error {
 	reply, err := c.Get(key)
 	return c.decode(reply, err, val)
 }
 
-// Set 存并设置有效时长。时长的单位为秒。
-// 基础类型直接保存，其他用json.Marshal后转成string保存。
-func (c *Cacher) Set(key string, val interface{}, expire int64) error {
+// Decode 将 Do 等方法返回的原始值写入 dest 。dest 为 *string 、 *[]byte 、 *int 、 *int64 、 *float64 、 *bool 时直接转换，
+// 其他类型（struct 、 map 、 slice 的指针等）使用 Options.Unmarshal 反序列化。reply 为nil时返回 ErrNil 。
+// Example:
+//
+// ```golang
+// var user User
+// err := c.Decode(reply, &user)
+// ```
+func (c *Cacher) Decode(reply interface{}, dest interface{}) error {
+	if reply == nil {
+		return ErrNil
+	}
+	var err error
+	switch d := dest.(type) {
+	case *string:
+		*d, err = redis.String(reply, nil)
+	case *[]byte:
+		*d, err = redis.Bytes(reply, nil)
+	case *int:
+		*d, err = redis.Int(reply, nil)
+	case *int64:
+		*d, err = redis.Int64(reply, nil)
+	case *float64:
+		*d, err = redis.Float64(reply, nil)
+	case *bool:
+		*d, err = redis.Bool(reply, nil)
+	default:
+		var str string
+		if str, err = redis.String(reply, nil); err == nil {
+			err = c.unmarshal([]byte(str), dest)
+		}
+	}
+	return err
+}
+
+// GetAny 根据键的类型读取整个键值，适用于通用的查看工具或管理界面。键不存在时返回 ErrNil 。
+// 返回值的类型为：string 类型返回 string ，list 和 set 类型返回 []string ，hash 类型返回 map[string]string ，zset 类型返回 []ZMember 。
+// 先通过 TYPE 命令获取类型再读取，两个命令之间键被修改时可能返回错误。
+func (c *Cacher) GetAny(key string) (interface{}, error) {
+	typ, err := String(c.Do("TYPE", c.getKey(key)))
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case "none":
+		return nil, ErrNil
+	case "string":
+		return String(c.Do("GET", c.getKey(key)))
+	case "list":
+		return redis.Strings(c.Do("LRANGE", c.getKey(key), 0, -1))
+	case "set":
+		return redis.Strings(c.Do("SMEMBERS", c.getKey(key)))
+	case "hash":
+		return redis.StringMap(c.Do("HGETALL", c.getKey(key)))
+	case "zset":
+		return toZMembers(c.Do("ZRANGE", c.getKey(key), 0, -1, "WITHSCORES"))
+	default:
+		return nil, fmt.Errorf("redisgo: unsupported type %s", typ)
+	}
+}
+
+// MGetT 通过 MGET 命令批量获取键值，按照 Decode 的方式解码为 T 类型，返回值的顺序与 keys 相同，不存在的键对应 T 的零值。
+// 任何一个值解码失败时返回 nil 和包含该键名的错误。集群模式下所有的键必须在同一个哈希槽中，参见 HashTag 。
+// Example:
+//
+// ```golang
+// users, err := redisgo.MGetT[User](c, "user:1", "user:2")
+// ```
+func MGetT[T any](c *Cacher, keys ...string) ([]T, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	args := redis.Args{}
+	for _, key := range keys {
+		args = args.Add(c.getKey(key))
+	}
+	values, err := redis.Values(c.Do("MGET", args...))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != len(keys) {
+		return nil, fmt.Errorf("redisgo: unexpected number of values, got %d", len(values))
+	}
+	result := make([]T, len(keys))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if err := c.Decode(value, &result[i]); err != nil {
+			return nil, fmt.Errorf("redisgo: decode %s: %w", keys[i], err)
+		}
+	}
+	return result, nil
+}
+
+// GetWithTTL 在同一个事务中获取键值及其剩余生存时间，键值按照 Decode 的方式写入 dest 。键不存在时返回 ErrNil 。
+// 键没有设置过期时间时 ttl 为 -1 。适用于需要根据剩余时间判断缓存新鲜度的场景，只需要一次往返。
+func (c *Cacher) GetWithTTL(key string, dest interface{}) (ttl time.Duration, err error) {
+	conn := c.getConn(c.getKey(key))
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("GET", c.getKey(key))
+	conn.Send("PTTL", c.getKey(key))
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	if len(values) != 2 {
+		return 0, fmt.Errorf("redisgo: unexpected number of values, got %d", len(values))
+	}
+	if err := c.Decode(values[0], dest); err != nil {
+		return 0, err
+	}
+	pttl, err := redis.Int64(values[1], nil)
+	if err != nil {
+		return 0, err
+	}
+	if pttl < 0 {
+		return -1, nil
+	}
+	return time.Duration(pttl) * time.Millisecond, nil
+}
+
+// GetTime 获取time.Time类型的键值，值须由 SetTime 保存
+func (c *Cacher) GetTime(key string) (time.Time, error) {
+	nsec, err := Int64(c.Get(key))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nsec), nil
+}
+
+// LCS 返回两个键的字符串值的最长公共子序列，可用于比较保存的字符串的差异。键不存在时视为空字符串。需要redis 7.0及以上版本。
+func (c *Cacher) LCS(key1, key2 string) (string, error) {
+	return String(c.Do("LCS", c.getKey(key1), c.getKey(key2)))
+}
+
+// LCSLen 返回两个键的字符串值的最长公共子序列的长度。需要redis 7.0及以上版本。
+func (c *Cacher) LCSLen(key1, key2 string) (int64, error) {
+	return Int64(c.Do("LCS", c.getKey(key1), c.getKey(key2), "LEN"))
+}
+
+// GetCached 与 GetObject 相同，但会先从进程内缓存中读取，未命中时再从redis读取并在进程内缓存 expire 秒。
+// 适用于读取频繁的热点键。需要通过 Options.LocalCacheSize 启用进程内缓存，否则等同于 GetObject。
+// 修改键值后，应调用 InvalidateCached 通知所有实例删除进程内缓存。
+func (c *Cacher) GetCached(key string, val interface{}, expire int64) error {
+	if c.local == nil {
+		return c.GetObject(key, val)
+	}
+	if str, ok := c.local.get(c.getKey(key)); ok {
+		return c.Decode([]byte(str), val)
+	}
+	str, err := String(c.Get(key))
+	if err != nil {
+		return err
+	}
+	c.local.set(c.getKey(key), str, time.Duration(expire)*time.Second)
+	return c.Decode([]byte(str), val)
+}
+
+// InvalidateCached 删除当前实例中键的进程内缓存，并通过 Options.InvalidateChannel 频道通知其他实例删除。
+// 不会删除redis中的键值。
+func (c *Cacher) InvalidateCached(key string) error {
+	if c.local == nil {
+		return nil
+	}
+	c.local.del(c.getKey(key))
+	_, err := c.Publish(c.invalidateChannel, c.getKey(key))
+	return err
+}
+
+// Set 存并设置有效时长。时长的单位为秒。
+// 基础类型直接保存，其他用json.Marshal后转成string保存。保存的值可以通过 GetObject 读取到相同类型的变量中。
+func (c *Cacher) Set(key string, val interface{}, expire int64) error {
+	value, err := c.encode(val)
+	if err != nil {
+		return err
+	}
+	if expire > 0 {
+		_, err := c.Do("SETEX", c.getKey(key), expire, value)
+		return err
+	}
+	_, err = c.Do("SET", c.getKey(key), value)
+	return err
+}
+
+// BitFieldOp BITFIELD 命令中的一个操作，通过 BitFieldGet 、 BitFieldSet 、 BitFieldIncrBy 创建
+type BitFieldOp struct {
+	Op     string // GET 、 SET 或 INCRBY
+	Type   string // 整数的类型， i 表示有符号整数， u 表示无符号整数，后面是位数，例如 u8 、 i16
+	Offset int64  // 整数在字符串中的位偏移量
+	Value  int64  // SET 设置的值或 INCRBY 增加的值，GET 时忽略
+}
+
+// BitFieldGet 读取指定类型和偏移量的整数
+func BitFieldGet(typ string, offset int64) BitFieldOp {
+	return BitFieldOp{Op: "GET", Type: typ, Offset: offset}
+}
+
+// BitFieldSet 设置指定类型和偏移量的整数，结果为设置前的值
+func BitFieldSet(typ string, offset int64, value int64) BitFieldOp {
+	return BitFieldOp{Op: "SET", Type: typ, Offset: offset, Value: value}
+}
+
+// BitFieldIncrBy 将指定类型和偏移量的整数加上 increment ，结果为增加后的值，溢出时回绕
+func BitFieldIncrBy(typ string, offset int64, increment int64) BitFieldOp {
+	return BitFieldOp{Op: "INCRBY", Type: typ, Offset: offset, Value: increment}
+}
+
+// BitField 在一个字符串键中读写多个指定位数的整数，返回值与 ops 一一对应。适用于在一个键中保存多个紧凑的计数器。
+// Example:
+//
+// ```golang
+// // 在同一个键中保存多个8位无符号计数器
+// values, err := c.BitField("user:1:counters", redisgo.BitFieldIncrBy("u8", 0, 1), redisgo.BitFieldGet("u8", 8))
+// ```
+func (c *Cacher) BitField(key string, ops ...BitFieldOp) ([]int64, error) {
+	args := redis.Args{}.Add(c.getKey(key))
+	for _, op := range ops {
+		switch op.Op {
+		case "GET":
+			args = args.Add(op.Op, op.Type, op.Offset)
+		case "SET", "INCRBY":
+			args = args.Add(op.Op, op.Type, op.Offset, op.Value)
+		default:
+			return nil, fmt.Errorf("redisgo: unknown bitfield operation %q", op.Op)
+		}
+	}
+	return redis.Int64s(c.Do("BITFIELD", args...))
+}
+
+// SetBytes 原样保存字节数组并设置有效时长，时长的单位为秒。
+// 适用于 protobuf 等二进制数据，通过 Set 保存的 []byte 会经过json序列化，不能原样读取。
+func (c *Cacher) SetBytes(key string, data []byte, expire int64) error {
+	if expire > 0 {
+		_, err := c.Do("SETEX", c.getKey(key), expire, data)
+		return err
+	}
+	_, err := c.Do("SET", c.getKey(key), data)
+	return err
+}
+
+// SetKeepTTL 更新键的值，并保留键原有的过期时间，键不存在时不设置过期时间。需要redis 6.0及以上版本。
+// 值的序列化方式与 Set 相同。
+func (c *Cacher) SetKeepTTL(key string, val interface{}) error {
+	value, err := c.encode(val)
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("SET", c.getKey(key), value, "KEEPTTL")
+	return err
+}
+
+// streamChunkSize GetToWriter 和 SetFromReader 每次读写的字节数
+const streamChunkSize = 64 * 1024
+
+// SetFromReader 读取 r 中的所有数据，分块通过 APPEND 保存到键中，并设置有效时长，时长的单位为秒。适用于不便一次性放入内存的大字符串。
+// 写入过程中其他客户端可能读取到不完整的值，需要避免时可以先写入临时键，再通过 PromoteKey 替换。
+func (c *Cacher) SetFromReader(key string, r io.Reader, expire int64) error {
+	conn := c.getConn(c.getKey(key))
+	defer conn.Close()
+	if _, err := conn.Do("SET", c.getKey(key), ""); err != nil {
+		return wrapError(err)
+	}
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, err := conn.Do("APPEND", c.getKey(key), buf[:n]); err != nil {
+				return wrapError(err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if expire > 0 {
+		if _, err := conn.Do("EXPIRE", c.getKey(key), expire); err != nil {
+			return wrapError(err)
+		}
+	}
+	return nil
+}
+
+// GetToWriter 分块通过 GETRANGE 读取键值并写入 w ，返回写入的字节数。键不存在时视为空字符串。
+func (c *Cacher) GetToWriter(key string, w io.Writer) (int64, error) {
+	conn := c.getConn(c.getKey(key))
+	defer conn.Close()
+	var written int64
+	for {
+		chunk, err := redis.Bytes(conn.Do("GETRANGE", c.getKey(key), written, written+streamChunkSize-1))
+		if err != nil {
+			return written, wrapError(err)
+		}
+		n, err := w.Write(chunk)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		if len(chunk) < streamChunkSize {
+			return written, nil
+		}
+	}
+}
+
+// SetTime 以Unix纳秒时间戳的形式保存time.Time类型的值，并设置有效时长。时长的单位为秒。
+func (c *Cacher) SetTime(key string, t time.Time, expire int64) error {
+	return c.Set(key, t.UnixNano(), expire)
+}
+
+// BulkSet 批量存并设置有效时长，时长的单位为秒。适用于从数据库加载大量数据预热缓存。
+// 命令通过管道发送，每 batchSize 个命令发送一次并读取结果，以控制占用的内存。batchSize 小于等于0时一次发送所有命令。
+// 遇到错误时停止并返回该错误，之前的批次已保存。
+func (c *Cacher) BulkSet(items map[string]interface{}, expire int64, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = len(items)
+	}
+	conn := c.track(c.pool.Get())
+	defer conn.Close()
+	pending := 0
+	flush := func() error {
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+		for ; pending > 0; pending-- {
+			if _, err := conn.Receive(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for key, val := range items {
+		value, err := c.encode(val)
+		if err != nil {
+			return err
+		}
+		if expire > 0 {
+			err = conn.Send("SETEX", c.getKey(key), expire, value)
+		} else {
+			err = conn.Send("SET", c.getKey(key), value)
+		}
+		if err != nil {
+			return err
+		}
+		pending++
+		if pending >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// SetWithTags 存并设置有效时长，同时将键加入到每个标签对应的集合中，之后可以通过 InvalidateTag 删除某个标签下的所有键。
+// 与 Set 相同，expire 的单位为秒，值为0时表示不过期。标签集合本身不会过期，会在 InvalidateTag 时删除。
+// 键和标签集合在同一个事务中写入，集群模式下键和所有标签集合（ "tag:" 加标签名）必须在同一个哈希槽中，参见 HashTag ，否则返回 CROSSSLOT 错误。
+func (c *Cacher) SetWithTags(key string, val interface{}, expire int64, tags ...string) error {
 	value, err := c.encode(val)
 	if err != nil {
 		return err
 	}
+	conn := c.getConn(c.getKey(key))
+	defer conn.Close()
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
 	if expire > 0 {
-		_, err := c.Do("SETEX", c.getKey(key), expire, value)
+		err = conn.Send("SETEX", c.getKey(key), expire, value)
+	} else {
+		err = conn.Send("SET", c.getKey(key), value)
+	}
+	if err != nil {
 		return err
 	}
-	_, err = c.Do("SET", c.getKey(key), value)
-	return err
+	for _, tag := range tags {
+		if err := conn.Send("SADD", c.getTagKey(tag), key); err != nil {
+			return err
+		}
+	}
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return wrapError(err)
+	}
+	// 事务中单个命令的错误不会让 EXEC 失败，而是作为对应位置的回复返回
+	for _, value := range values {
+		if err, ok := value.(redis.Error); ok {
+			return wrapError(err)
+		}
+	}
+	return nil
+}
+
+// InvalidateTag 删除标签下的所有键以及标签集合本身，返回删除的键的数量（不包括标签集合）。
+func (c *Cacher) InvalidateTag(tag string) (int64, error) {
+	tagKey := c.getTagKey(tag)
+	conn := c.getConn(tagKey)
+	defer conn.Close()
+	for {
+		if _, err := conn.Do("WATCH", tagKey); err != nil {
+			return 0, err
+		}
+		keys, err := redis.Strings(conn.Do("SMEMBERS", tagKey))
+		if err != nil {
+			return 0, err
+		}
+		args := redis.Args{}
+		for _, key := range keys {
+			args = args.Add(c.getKey(key))
+		}
+		conn.Send("MULTI")
+		if len(args) > 0 {
+			conn.Send("DEL", args...)
+		}
+		conn.Send("DEL", tagKey)
+		values, err := redis.Values(conn.Do("EXEC"))
+		if err == ErrNil {
+			// 标签集合在执行期间被修改，重新读取
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(args) == 0 {
+			return 0, nil
+		}
+		return Int64(values[0], nil)
+	}
 }
 
 // Exists 检查键是否存在
@@ -163,29 +1061,234 @@ func (c *Cacher) Exists(key string) (bool, error) {
 	return Bool(c.Do("EXISTS", c.getKey(key)))
 }
 
-//Del 删除键
+// Del 删除键
 func (c *Cacher) Del(key string) error {
 	_, err := c.Do("DEL", c.getKey(key))
 	return err
 }
 
-// Flush 清空当前数据库中的所有 key，慎用！
+// Flush 清空当前数据库中的所有 key，慎用！需要通过 Options.AllowDangerousCommands 启用。
 func (c *Cacher) Flush() error {
 	_, err := c.Do("FLUSHDB")
 	return err
 }
 
+// FlushAll 清空所有数据库中的所有 key，慎用！async 为 true 时在后台异步释放内存。需要通过 Options.AllowDangerousCommands 启用。
+func (c *Cacher) FlushAll(async bool) error {
+	args := redis.Args{}
+	if async {
+		args = args.Add("ASYNC")
+	}
+	_, err := c.Do("FLUSHALL", args...)
+	return err
+}
+
+// Move 将键从当前数据库（ Options.Db ）移动到数据库 db ，返回是否移动成功。键不存在或 db 中已存在同名的键时不移动。
+// 连接池中的连接都使用 Options.Db ，所以移动后需要使用另一个配置了目标数据库的实例访问该键。集群模式不支持多个数据库。
+func (c *Cacher) Move(key string, db int) (bool, error) {
+	return Bool(c.Do("MOVE", c.getKey(key), db))
+}
+
 // TTL 以秒为单位。当 key 不存在时，返回 -2 。 当 key 存在但没有设置剩余生存时间时，返回 -1
 func (c *Cacher) TTL(key string) (ttl int64, err error) {
 	return Int64(c.Do("TTL", c.getKey(key)))
 }
 
+// MissingKeys 通过管道批量检查多个键是否存在，按 keys 中的顺序返回不存在的键，适用于计算需要从数据库加载的键。
+func (c *Cacher) MissingKeys(keys ...string) ([]string, error) {
+	exists, err := c.existsEach(keys)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for i, key := range keys {
+		if !exists[i] {
+			missing = append(missing, key)
+		}
+	}
+	return missing, nil
+}
+
+// ExistsMap 通过管道批量检查多个键是否存在，返回每个键是否存在的映射，包含 keys 中的所有键。
+func (c *Cacher) ExistsMap(keys ...string) (map[string]bool, error) {
+	exists, err := c.existsEach(keys)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		result[key] = exists[i]
+	}
+	return result, nil
+}
+
+// existsEach 通过管道对每个键执行 EXISTS ，返回与 keys 一一对应的结果
+func (c *Cacher) existsEach(keys []string) ([]bool, error) {
+	conn := c.track(c.pool.Get())
+	defer conn.Close()
+	for _, key := range keys {
+		if err := conn.Send("EXISTS", c.getKey(key)); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	exists := make([]bool, len(keys))
+	for i := range keys {
+		var err error
+		if exists[i], err = Bool(conn.Receive()); err != nil {
+			return nil, err
+		}
+	}
+	return exists, nil
+}
+
+// ScanChan 在后台协程中通过 SCAN 命令遍历匹配 match 的键，并通过返回的通道依次发送键名（不含前缀），遍历完成后关闭通道。
+// match 会自动加上键名前缀，count 为每次 SCAN 的建议数量。遍历出错时，错误通过第二个通道返回；
+// ctx 被取消时停止遍历并返回 ctx.Err() 。调用方停止读取键名时应取消 ctx ，否则后台协程不会退出。
+// 与 SCAN 命令相同，遍历期间被修改的键可能被遗漏或重复返回。集群模式下只遍历第一个节点。
+// Example:
+//
+// ```golang
+// keys, errc := c.ScanChan(ctx, "user:*", 100)
+// for key := range keys {
+// fmt.Println(key)
+// }
+// err := <-errc
+// ```
+func (c *Cacher) ScanChan(ctx context.Context, match string, count int) (<-chan string, <-chan error) {
+	keys := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(keys)
+		errc <- c.scan(ctx, match, count, keys)
+	}()
+	return keys, errc
+}
+
+// scan 遍历匹配 match 的键并发送到 keys ，直到遍历完成或 ctx 被取消
+func (c *Cacher) scan(ctx context.Context, match string, count int, keys chan<- string) error {
+	args := redis.Args{}.Add("MATCH", c.getKey(match))
+	if count > 0 {
+		args = args.Add("COUNT", count)
+	}
+	cursor := "0"
+	for {
+		values, err := redis.Values(c.Do("SCAN", redis.Args{}.Add(cursor).Add(args...)...))
+		if err != nil {
+			return err
+		}
+		if len(values) != 2 {
+			return fmt.Errorf("redisgo: unexpected number of values, got %d", len(values))
+		}
+		if cursor, err = redis.String(values[0], nil); err != nil {
+			return err
+		}
+		batch, err := redis.Strings(values[1], nil)
+		if err != nil {
+			return err
+		}
+		for _, key := range batch {
+			select {
+			case keys <- strings.TrimPrefix(key, c.prefix):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if cursor == "0" {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// TTLMulti 通过管道批量获取多个键的剩余生存时间，以秒为单位，返回键到剩余生存时间的映射。
+// 与 TTL 相同，键不存在时为 -2 ，键存在但没有设置剩余生存时间时为 -1 。
+func (c *Cacher) TTLMulti(keys ...string) (map[string]int64, error) {
+	conn := c.track(c.pool.Get())
+	defer conn.Close()
+	for _, key := range keys {
+		if err := conn.Send("TTL", c.getKey(key)); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	ttls := make(map[string]int64, len(keys))
+	for _, key := range keys {
+		ttl, err := Int64(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		ttls[key] = ttl
+	}
+	return ttls, nil
+}
+
 // Expire 设置键过期时间，expire的单位为秒
 func (c *Cacher) Expire(key string, expire int64) error {
 	_, err := Bool(c.Do("EXPIRE", c.getKey(key), expire))
 	return err
 }
 
+// ExpireOpts 按条件设置键过期时间，expire的单位为秒。需要redis 7.0及以上版本。
+// flag 可以是以下几种：
+// NX : 只有键没有过期时间时才设置。
+// XX : 只有键已有过期时间时才设置。
+// GT : 只有新的过期时间大于当前过期时间时才设置，可用于只延长不缩短。
+// LT : 只有新的过期时间小于当前过期时间时才设置。
+// 为空时与 Expire 相同。返回值表示是否设置了过期时间，例如延长锁的有效期时使用 GT ，不会覆盖已有的更长的过期时间。
+// flag 不是以上几种时返回错误，不会发送命令。
+func (c *Cacher) ExpireOpts(key string, expire int64, flag string) (bool, error) {
+	args := redis.Args{}.Add(c.getKey(key), expire)
+	switch flag {
+	case "":
+	case "NX", "XX", "GT", "LT":
+		args = args.Add(flag)
+	default:
+		return false, fmt.Errorf("redisgo: unknown expire flag %q", flag)
+	}
+	return Bool(c.Do("EXPIRE", args...))
+}
+
+// ObjectEncoding 返回键的值所使用的内部编码，例如 listpack、ziplist、hashtable、intset 等，可用于分析内存使用情况。
+func (c *Cacher) ObjectEncoding(key string) (string, error) {
+	return String(c.Do("OBJECT", "ENCODING", c.getKey(key)))
+}
+
+// ObjectIdleTime 返回键自上次被访问以来的空闲时间，单位为秒。
+func (c *Cacher) ObjectIdleTime(key string) (int64, error) {
+	return Int64(c.Do("OBJECT", "IDLETIME", c.getKey(key)))
+}
+
+// ObjectRefCount 返回键的值的引用计数。
+func (c *Cacher) ObjectRefCount(key string) (int64, error) {
+	return Int64(c.Do("OBJECT", "REFCOUNT", c.getKey(key)))
+}
+
+// ErrLFUDisabled 服务器的 maxmemory-policy 不是LFU策略，没有记录访问频率时， ObjectFreq 返回该错误
+var ErrLFUDisabled = errors.New("redisgo: maxmemory-policy is not an LFU policy, access frequency is not tracked")
+
+// ObjectFreq 返回键的对数访问频率计数器，可用于验证淘汰策略和查找冷数据。
+// 服务器的 maxmemory-policy 需要为 allkeys-lfu 或 volatile-lfu ，否则返回 ErrLFUDisabled 。
+func (c *Cacher) ObjectFreq(key string) (int64, error) {
+	freq, err := Int64(c.Do("OBJECT", "FREQ", c.getKey(key)))
+	if err != nil && strings.Contains(err.Error(), "LFU") {
+		return 0, ErrLFUDisabled
+	}
+	return freq, err
+}
+
+// MemoryUsage 返回键及其值占用的内存字节数，可用于查找占用内存较多的键。键不存在时返回 ErrNil 。
+func (c *Cacher) MemoryUsage(key string) (int64, error) {
+	return Int64(c.Do("MEMORY", "USAGE", c.getKey(key)))
+}
+
 // Incr 将 key 中储存的数字值增一
 func (c *Cacher) Incr(key string) (val int64, err error) {
 	return Int64(c.Do("INCR", c.getKey(key)))
@@ -216,7 +1319,7 @@ func (c *Cacher) DecrBy(key string, amount int64) (val int64, err error) {
 // err := c.HMSet("user", m, 10)
 // ```
 func (c *Cacher) HMSet(key string, val interface{}, expire int) (err error) {
-	conn := c.pool.Get()
+	conn := c.getConn(c.getKey(key))
 	defer conn.Close()
 	err = conn.Send("HMSET", redis.Args{}.Add(c.getKey(key)).AddFlat(val)...)
 	if err != nil {
@@ -233,6 +1336,95 @@ func (c *Cacher) HMSet(key string, val interface{}, expire int) (err error) {
 	return
 }
 
+// HMSetMap 将一个map中的字段存到Redis hash，同时设置有效期，单位：秒。
+// 与 HMSet 不同，非基本类型的字段值会用json.Marshal后转成string保存，适用于字段不固定的场景。
+// Example:
+//
+// ```golang
+// m := map[string]interface{}{"name": "corel", "tags": []string{"a", "b"}}
+// err := c.HMSetMap("user", m, 10)
+// ```
+func (c *Cacher) HMSetMap(key string, fields map[string]interface{}, expire int) (err error) {
+	if len(fields) == 0 {
+		return errors.New("redisgo: no fields to set")
+	}
+	args := redis.Args{}.Add(c.getKey(key))
+	for field, val := range fields {
+		value, err := c.encode(val)
+		if err != nil {
+			return err
+		}
+		args = args.Add(field, value)
+	}
+	conn := c.getConn(c.getKey(key))
+	defer conn.Close()
+	err = conn.Send("HMSET", args...)
+	if err != nil {
+		return
+	}
+	pending := 1
+	if expire > 0 {
+		if err = conn.Send("EXPIRE", c.getKey(key), int64(expire)); err != nil {
+			return
+		}
+		pending++
+	}
+	if err = conn.Flush(); err != nil {
+		return
+	}
+	for ; pending > 0; pending-- {
+		if _, err = conn.Receive(); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// SetStruct 将结构体的字段保存为哈希表，并设置有效时长，单位为秒。
+// 在同一个事务中删除旧的哈希表、写入字段并设置过期时间，读取方不会看到新旧字段混合或没有过期时间的哈希表。
+// 字段名默认为结构体字段名，可以通过 redis 标签修改，规则与 redis.Args.AddFlat 相同，可以使用 GetStruct 读取。
+// Example:
+//
+// ```golang
+// err := c.SetStruct("user:1", &User{Name: "corel", Age: 23}, 600)
+// var user User
+// err = c.GetStruct("user:1", &user)
+// ```
+func (c *Cacher) SetStruct(key string, v interface{}, expire int64) error {
+	conn := c.getConn(c.getKey(key))
+	defer conn.Close()
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	if err := conn.Send("DEL", c.getKey(key)); err != nil {
+		return err
+	}
+	if err := conn.Send("HSET", redis.Args{}.Add(c.getKey(key)).AddFlat(v)...); err != nil {
+		return err
+	}
+	if expire > 0 {
+		if err := conn.Send("EXPIRE", c.getKey(key), expire); err != nil {
+			return err
+		}
+	}
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return wrapError(err)
+	}
+	// 事务中单个命令的错误不会让 EXEC 失败，而是作为对应位置的回复返回
+	for _, value := range values {
+		if err, ok := value.(redis.Error); ok {
+			return wrapError(err)
+		}
+	}
+	return nil
+}
+
+// GetStruct 读取通过 SetStruct 保存的哈希表并写入结构体 v ，与 HGetAll 相同，哈希表不存在时返回 ErrNil
+func (c *Cacher) GetStruct(key string, v interface{}) error {
+	return c.HGetAll(key, v)
+}
+
 /** Redis hash 是一个string类型的field和value的映射表，hash特别适合用于存储对象。 **/
 
 // HSet 将哈希表 key 中的字段 field 的值设为 val
@@ -249,6 +1441,16 @@ func (c *Cacher) HSet(key, field string, val interface{}) (interface{}, error) {
 	return c.Do("HSET", c.getKey(key), field, value)
 }
 
+// HSetNX 只有当哈希表 key 中的字段 field 不存在时，才将其值设为 val ，返回值表示是否设置了字段的值。
+// 适用于初始化字段的默认值而不覆盖已有的值。值的序列化方式与 HSet 相同。
+func (c *Cacher) HSetNX(key, field string, val interface{}) (bool, error) {
+	value, err := c.encode(val)
+	if err != nil {
+		return false, err
+	}
+	return Bool(c.Do("HSETNX", c.getKey(key), field, value))
+}
+
 // HGet 获取存储在哈希表中指定字段的值
 // Example:
 //
@@ -290,18 +1492,34 @@ func (c *Cacher) HGetObject(key, field string, val interface{}) error {
 	return c.decode(reply, err, val)
 }
 
-// HGetAll HGetAll("key", &val)
+// HGetAll 读取哈希表的所有字段并写入结构体 val ，字段名的对应规则与 redis.ScanStruct 相同。
+// 哈希表不存在时返回 ErrNil ；哈希表存在但字段值不能转换为结构体字段的类型时，返回 ScanStruct 的错误。
+// Example:
+//
+// ```golang
+// var user User
+// err := c.HGetAll("user", &user)
+// ```
 func (c *Cacher) HGetAll(key string, val interface{}) error {
 	v, err := redis.Values(c.Do("HGETALL", c.getKey(key)))
 	if err != nil {
 		return err
 	}
-
-	if err := redis.ScanStruct(v, val); err != nil {
-		fmt.Println(err)
+	if len(v) == 0 {
+		return ErrNil
 	}
-	//fmt.Printf("%+v\n", val)
-	return err
+	return redis.ScanStruct(v, val)
+}
+
+// HRandField 从哈希表中随机返回 count 个字段。需要redis 6.2及以上版本。
+// count 为正数时返回的字段各不相同，数量不超过哈希表的字段数；count 为负数时返回的字段可能重复，数量为 count 的绝对值。
+func (c *Cacher) HRandField(key string, count int) ([]string, error) {
+	return redis.Strings(c.Do("HRANDFIELD", c.getKey(key), count))
+}
+
+// HRandFieldWithValues 与 HRandField 相同，但同时返回字段的值。count 为负数时重复的字段在结果中只出现一次。
+func (c *Cacher) HRandFieldWithValues(key string, count int) (map[string]string, error) {
+	return redis.StringMap(c.Do("HRANDFIELD", c.getKey(key), count, "WITHVALUES"))
 }
 
 /**
@@ -478,6 +1696,71 @@ func (c *Cacher) LREM(key string, count int, member interface{}) (int, error) {
 	return Int(c.Do("LREM", c.getKey(key), count, member))
 }
 
+// LInsert 将 value 插入到列表中第一个与 pivot 相等的元素之前（before 为 true）或之后。
+// 返回插入后列表的长度；找不到 pivot 时返回 -1 ，列表不存在时返回 0 。pivot 和 value 会按照与 LPush 相同的方式序列化。
+func (c *Cacher) LInsert(key string, before bool, pivot, value interface{}) (int64, error) {
+	pivotValue, err := c.encode(pivot)
+	if err != nil {
+		return 0, err
+	}
+	val, err := c.encode(value)
+	if err != nil {
+		return 0, err
+	}
+	where := "AFTER"
+	if before {
+		where = "BEFORE"
+	}
+	return Int64(c.Do("LINSERT", c.getKey(key), where, pivotValue, val))
+}
+
+// LSet 将列表中下标为 index 的元素设为 value ，下标超出范围或列表不存在时返回错误。
+// 与 LRange 相同，可以使用负数下标， -1 表示列表的最后一个元素。
+func (c *Cacher) LSet(key string, index int, value interface{}) error {
+	val, err := c.encode(value)
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("LSET", c.getKey(key), index, val)
+	return err
+}
+
+// LIndex 返回列表中下标为 index 的元素，下标超出范围或列表不存在时返回 ErrNil 。可以使用负数下标。
+func (c *Cacher) LIndex(key string, index int) (string, error) {
+	return String(c.Do("LINDEX", c.getKey(key), index))
+}
+
+// RPopLPush 原子地移出列表 src 的最后一个元素（表尾，右边），插入到列表 dst 的头部（表头，左边），并返回该元素。
+// src 为空时返回 ErrNil 。src 和 dst 相同时，相当于将表尾的元素移到表头，可以用于轮转列表。
+func (c *Cacher) RPopLPush(src, dst string) (string, error) {
+	return String(c.Do("RPOPLPUSH", c.getKey(src), c.getKey(dst)))
+}
+
+// LMove 原子地移出列表 src 一端的元素，插入到列表 dst 的一端，并返回该元素。需要redis 6.2及以上版本。
+// srcSide 、 dstSide 的值为 LEFT（表头）或 RIGHT（表尾）。src 为空时返回 ErrNil 。
+func (c *Cacher) LMove(src, dst, srcSide, dstSide string) (string, error) {
+	return String(c.Do("LMOVE", c.getKey(src), c.getKey(dst), srcSide, dstSide))
+}
+
+// LPos 返回列表中第一个与 member 相等的元素的下标，不存在时返回 ErrNil 。需要redis 6.0.6及以上版本。
+// member 会按照与 LPush 、 RPush 相同的方式序列化后再比较。
+func (c *Cacher) LPos(key string, member interface{}) (int64, error) {
+	value, err := c.encode(member)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(c.Do("LPOS", c.getKey(key), value))
+}
+
+// LPosCount 返回列表中前 count 个与 member 相等的元素的下标，count 为0时返回所有匹配的元素的下标。需要redis 6.0.6及以上版本。
+func (c *Cacher) LPosCount(key string, member interface{}, count int) ([]int64, error) {
+	value, err := c.encode(member)
+	if err != nil {
+		return nil, err
+	}
+	return redis.Int64s(c.Do("LPOS", c.getKey(key), value, "COUNT", count))
+}
+
 // LLen 获取列表的长度
 func (c *Cacher) LLen(key string) (int64, error) {
 	return Int64(c.Do("RPOP", c.getKey(key)))
@@ -491,6 +1774,108 @@ func (c *Cacher) LRange(key string, start, end int) (interface{}, error) {
 	return c.Do("LRANGE", c.getKey(key), start, end)
 }
 
+// SortOptions 用于SORT命令的参数
+type SortOptions struct {
+	By     string   // 使用其他键的值作为排序的权重，例如 weight_*，会自动加上键名前缀。值为 nosort 时表示不排序
+	Get    []string // 根据排序的结果取出其他键的值，例如 name_*，会自动加上键名前缀。# 表示取出元素本身
+	Offset int      // 跳过的元素数量
+	Count  int      // 返回的元素数量，值为0时表示不限制
+	Alpha  bool     // 按字典序排序，元素不是数字时必须设置
+	Desc   bool     // 从大到小排序，默认为从小到大
+}
+
+// Sort 返回或保存给定列表、集合、有序集合 key 中经过排序的元素。
+func (c *Cacher) Sort(key string, options SortOptions) ([]string, error) {
+	args := redis.Args{}.Add(c.getKey(key))
+	if options.By != "" {
+		if options.By == "nosort" {
+			args = args.Add("BY", options.By)
+		} else {
+			args = args.Add("BY", c.getKey(options.By))
+		}
+	}
+	if options.Count > 0 {
+		args = args.Add("LIMIT", options.Offset, options.Count)
+	}
+	for _, pattern := range options.Get {
+		if pattern == "#" {
+			args = args.Add("GET", pattern)
+		} else {
+			args = args.Add("GET", c.getKey(pattern))
+		}
+	}
+	if options.Desc {
+		args = args.Add("DESC")
+	}
+	if options.Alpha {
+		args = args.Add("ALPHA")
+	}
+	return redis.Strings(c.Do("SORT", args...))
+}
+
+/**
+Redis 的 Set 是 String 类型的无序集合。集合成员是唯一的，这就意味着集合中不能出现重复的数据。
+**/
+
+// SInterCard 返回给定的所有集合的交集的元素数量，而不返回交集本身。需要redis 7.0及以上版本。
+// limit 大于0时，计数达到 limit 后停止计算并返回 limit，可以在只关心交集是否足够大时减少计算量；limit 为0时表示不限制，小于0时返回错误。
+func (c *Cacher) SInterCard(limit int, keys ...string) (int64, error) {
+	args, err := c.interCardArgs(limit, keys)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(c.Do("SINTERCARD", args...))
+}
+
+// interCardArgs 生成 SINTERCARD 、 ZINTERCARD 命令的参数
+func (c *Cacher) interCardArgs(limit int, keys []string) (redis.Args, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("redisgo: at least one key is required")
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("redisgo: limit must not be negative, got %d", limit)
+	}
+	args := redis.Args{}.Add(len(keys))
+	for _, key := range keys {
+		args = args.Add(c.getKey(key))
+	}
+	if limit > 0 {
+		args = args.Add("LIMIT", limit)
+	}
+	return args, nil
+}
+
+// SMIsMember 检查多个 member 是否是集合的成员，返回与 members 一一对应的结果。需要redis 6.2及以上版本。
+func (c *Cacher) SMIsMember(key string, members ...interface{}) ([]bool, error) {
+	args := redis.Args{}.Add(c.getKey(key))
+	for _, member := range members {
+		value, err := c.encode(member)
+		if err != nil {
+			return nil, err
+		}
+		args = args.Add(value)
+	}
+	values, err := redis.Ints(c.Do("SMISMEMBER", args...))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]bool, len(values))
+	for i, v := range values {
+		results[i] = v == 1
+	}
+	return results, nil
+}
+
+// SMove 原子地将 member 从集合 src 移动到集合 dst ，返回 member 是否是 src 的成员。
+// 适用于状态机等场景，例如将任务从 pending 集合移动到 done 集合。member 会按照与 SMIsMember 相同的方式序列化。
+func (c *Cacher) SMove(src, dst string, member interface{}) (bool, error) {
+	value, err := c.encode(member)
+	if err != nil {
+		return false, err
+	}
+	return Bool(c.Do("SMOVE", c.getKey(src), c.getKey(dst), value))
+}
+
 /**
 Redis 有序集合和集合一样也是string类型元素的集合,且不允许重复的成员。
 不同的是每个元素都会关联一个double类型的分数。redis正是通过分数来为集合中的成员进行从小到大的排序。
@@ -498,6 +1883,12 @@ Redis 有序集合和集合一样也是string类型元素的集合,且不允许
 集合是通过哈希表实现的，所以添加，删除，查找的复杂度都是O(1)。
 **/
 
+// ZMember 有序集合的成员及其 score 值
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
 // ZAdd 将一个 member 元素及其 score 值加入到有序集 key 当中。
 func (c *Cacher) ZAdd(key string, score int64, member string) (reply interface{}, err error) {
 	return c.Do("ZADD", c.getKey(key), score, member)
@@ -513,6 +1904,27 @@ func (c *Cacher) ZScore(key string, member string) (int64, error) {
 	return Int64(c.Do("ZSCORE", c.getKey(key), member))
 }
 
+// ZMScore 返回有序集 key 中多个成员的 score 值，与 members 一一对应。需要redis 6.2及以上版本。
+// present 表示对应的成员是否存在，不存在的成员 score 值为0。
+func (c *Cacher) ZMScore(key string, members ...string) (scores []float64, present []bool, err error) {
+	values, err := redis.Values(c.Do("ZMSCORE", redis.Args{}.Add(c.getKey(key)).AddFlat(members)...))
+	if err != nil {
+		return nil, nil, err
+	}
+	scores = make([]float64, len(values))
+	present = make([]bool, len(values))
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		if scores[i], err = redis.Float64(v, nil); err != nil {
+			return nil, nil, err
+		}
+		present[i] = true
+	}
+	return scores, present, nil
+}
+
 // ZRank 返回有序集中指定成员的排名。其中有序集成员按分数值递增(从小到大)顺序排列。score 值最小的成员排名为 0
 func (c *Cacher) ZRank(key, member string) (int64, error) {
 	return Int64(c.Do("ZRANK", c.getKey(key), member))
@@ -547,6 +1959,117 @@ func (c *Cacher) ZRevrangeByScore(key string, from, to, offset int64, count int)
 	return redis.Int64Map(c.Do("ZREVRANGEBYSCORE", c.getKey(key), from, to, "WITHSCORES", "LIMIT", offset, count))
 }
 
+// ZRangeByLex 当有序集合的所有成员都具有相同的分值时，返回成员介于 min 和 max 之间的成员，成员按字典序递增排列。
+// 分值不同时，返回的结果是不确定的，所以只应在所有成员的分值都相同时使用，例如用来实现按前缀自动补全。
+// min 和 max 必须以 [ (包含) 或 ( (不包含) 开头，例如 "[a"、"(b"；也可以使用 - 和 + 分别表示最小值和最大值。
+// offset 和 count 与 LIMIT 参数相同，count 为负数时返回 offset 之后的所有成员。
+func (c *Cacher) ZRangeByLex(key, min, max string, offset, count int) ([]string, error) {
+	return redis.Strings(c.Do("ZRANGEBYLEX", c.getKey(key), min, max, "LIMIT", offset, count))
+}
+
+// ZRevrangeByLex 与 ZRangeByLex 相同，但成员按字典序递减排列。注意参数的顺序为先 max 后 min。
+func (c *Cacher) ZRevrangeByLex(key, max, min string, offset, count int) ([]string, error) {
+	return redis.Strings(c.Do("ZREVRANGEBYLEX", c.getKey(key), max, min, "LIMIT", offset, count))
+}
+
+// ZRandMember 从有序集中随机返回 count 个成员。需要redis 6.2及以上版本。
+// count 为正数时返回的成员各不相同，数量不超过有序集的成员数；count 为负数时返回的成员可能重复，数量为 count 的绝对值。
+func (c *Cacher) ZRandMember(key string, count int) ([]string, error) {
+	return redis.Strings(c.Do("ZRANDMEMBER", c.getKey(key), count))
+}
+
+// ZRandMemberWithScores 与 ZRandMember 相同，但同时返回成员的 score 值。
+func (c *Cacher) ZRandMemberWithScores(key string, count int) ([]ZMember, error) {
+	return toZMembers(c.Do("ZRANDMEMBER", c.getKey(key), count, "WITHSCORES"))
+}
+
+// toZMembers 将成员和 score 值交替排列的回复转换为 ZMember 列表
+func toZMembers(reply interface{}, err error) ([]ZMember, error) {
+	values, err := redis.Strings(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redisgo: expects even number of values result")
+	}
+	members := make([]ZMember, len(values)/2)
+	for i := range members {
+		score, err := strconv.ParseFloat(values[2*i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		members[i] = ZMember{Member: values[2*i], Score: score}
+	}
+	return members, nil
+}
+
+// ZUnionStore 计算给定的一个或多个有序集的并集，并将结果储存到 dest 中，返回 dest 中的成员数量。
+// weights 为每个有序集指定一个乘法因子，为空时默认都为1，不为空时长度必须与 keys 相同。
+// aggregate 指定并集中成员分值的计算方式，可以是 SUM、MIN 或 MAX，为空时默认为 SUM。
+func (c *Cacher) ZUnionStore(dest string, keys []string, weights []float64, aggregate string) (int64, error) {
+	args, err := c.zStoreArgs(dest, keys, weights, aggregate)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(c.Do("ZUNIONSTORE", args...))
+}
+
+// ZInterStore 计算给定的一个或多个有序集的交集，并将结果储存到 dest 中，返回 dest 中的成员数量。
+// weights 和 aggregate 参数的含义与 ZUnionStore 相同。
+func (c *Cacher) ZInterStore(dest string, keys []string, weights []float64, aggregate string) (int64, error) {
+	args, err := c.zStoreArgs(dest, keys, weights, aggregate)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(c.Do("ZINTERSTORE", args...))
+}
+
+// ZInterCard 返回给定的所有有序集的交集的成员数量，而不返回交集本身。需要redis 7.0及以上版本。
+// limit 的含义与 SInterCard 相同。
+func (c *Cacher) ZInterCard(limit int, keys ...string) (int64, error) {
+	args, err := c.interCardArgs(limit, keys)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(c.Do("ZINTERCARD", args...))
+}
+
+// ZDiff 返回第一个有序集中不在其他有序集中的成员及其分值，按分值从小到大排序。需要redis 6.2及以上版本。
+func (c *Cacher) ZDiff(keys ...string) ([]ZMember, error) {
+	args := redis.Args{}.Add(len(keys))
+	for _, key := range keys {
+		args = args.Add(c.getKey(key))
+	}
+	return toZMembers(c.Do("ZDIFF", args.Add("WITHSCORES")...))
+}
+
+// ZDiffStore 计算第一个有序集与其他有序集的差集，并将结果储存到 dest 中，返回 dest 中的成员数量。需要redis 6.2及以上版本。
+func (c *Cacher) ZDiffStore(dest string, keys ...string) (int64, error) {
+	args := redis.Args{}.Add(c.getKey(dest), len(keys))
+	for _, key := range keys {
+		args = args.Add(c.getKey(key))
+	}
+	return Int64(c.Do("ZDIFFSTORE", args...))
+}
+
+// zStoreArgs 构造ZUNIONSTORE和ZINTERSTORE命令的参数
+func (c *Cacher) zStoreArgs(dest string, keys []string, weights []float64, aggregate string) (redis.Args, error) {
+	if len(weights) > 0 && len(weights) != len(keys) {
+		return nil, fmt.Errorf("redisgo: got %d weights for %d keys", len(weights), len(keys))
+	}
+	args := redis.Args{}.Add(c.getKey(dest), len(keys))
+	for _, key := range keys {
+		args = args.Add(c.getKey(key))
+	}
+	if len(weights) > 0 {
+		args = args.Add("WEIGHTS").AddFlat(weights)
+	}
+	if aggregate != "" {
+		args = args.Add("AGGREGATE", aggregate)
+	}
+	return args, nil
+}
+
 /**
 Redis 发布订阅(pub/sub)是一种消息通信模式：发送者(pub)发送消息，订阅者(sub)接收消息。
 Redis 客户端可以订阅任意数量的频道。
@@ -560,45 +2083,25 @@ func (c *Cacher) Publish(channel, message string) (int, error) {
 
 // Subscribe 订阅给定的一个或多个频道的信息。
 // 支持redis服务停止或网络异常等情况时，自动重新订阅。
-// 一般的程序都是启动后开启一些固定channel的订阅，也不会动态的取消订阅，这种场景下可以使用本方法。
+// 服务器确认所有频道的订阅后才返回，返回之后发布到这些频道的消息都能收到，不需要等待一段时间再发布。
+// 连接在确认前断开时也会返回，此时频道在重新连接后订阅，可以通过 Subscription.OnSubscribed 得知订阅的确认。
+// 一般的程序都是启动后开启一些固定channel的订阅，这种场景下可以直接使用本方法；需要动态增加或取消订阅的频道时，可以使用返回的 Subscription 。
 // 复杂场景的使用可以直接参考 https://godoc.org/github.com/gomodule/redigo/redis#hdr-Publish_and_Subscribe
-func (c *Cacher) Subscribe(onMessage func(channel string, data []byte) error, channels ...string) error {
-	conn := c.pool.Get()
-	psc := redis.PubSubConn{Conn: conn}
-	err := psc.Subscribe(redis.Args{}.AddFlat(channels)...)
+func (c *Cacher) Subscribe(onMessage func(channel string, data []byte) error, channels ...string) (*Subscription, error) {
+	s := newSubscription(c, onMessage, channels...)
+	s.mu.Lock()
+	waits := s.expect(channels)
+	s.mu.Unlock()
+	psc, err := s.connect()
 	// 如果订阅失败，休息1秒后重新订阅（比如当redis服务停止服务或网络异常）
-	if err != nil {
-		fmt.Println(err)
+	for err != nil {
+		c.logger.Printf("subscribe: %v", err)
 		time.Sleep(time.Second)
-		return c.Subscribe(onMessage, channels...)
+		psc, err = s.connect()
 	}
-	quit := make(chan int, 1)
-
-	// 处理消息
-	go func() {
-		for {
-			switch v := psc.Receive().(type) {
-			case redis.Message:
-				// fmt.Printf("%s: message: %s\n", v.Channel, v.Data)
-				go onMessage(v.Channel, v.Data)
-			case redis.Subscription:
-				fmt.Printf("%s: %s %d\n", v.Channel, v.Kind, v.Count)
-			case error:
-				quit <- 1
-				fmt.Println(err)
-				return
-			}
-		}
-	}()
-
-	// 异常情况下自动重新订阅
-	go func() {
-		<-quit
-		time.Sleep(time.Second)
-		psc.Close()
-		c.Subscribe(onMessage, channels...)
-	}()
-	return err
+	go s.run(psc)
+	wait(waits)
+	return s, nil
 }
 
 /**
@@ -770,11 +2273,490 @@ func toGeoResult(reply interface{}, err error, options GeoOptions) ([]*GeoResult
 	return results, nil
 }
 
+/**
+RedisJSON 模块，需要服务器加载RedisJSON模块
+**/
+
+// JSONSet 将 val 序列化为json后保存到 key 中 path 指定的位置，例如 $ 表示整个文档，$.name 表示name字段。
+// 只修改 path 指定的部分，不需要重写整个文档。key 不存在时 path 只能是根路径。
+func (c *Cacher) JSONSet(key, path string, val interface{}) error {
+	b, err := c.marshal(val)
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("JSON.SET", c.getKey(key), path, string(b))
+	return err
+}
+
+// JSONGet 读取 key 中 path 指定位置的值，并反序列化到 dest 中。key 或 path 不存在时返回 ErrNil 。
+// path 以 $ 开头时返回的是所有匹配的值组成的数组，dest 应为切片；以 . 开头（旧版语法）时返回单个值。
+func (c *Cacher) JSONGet(key, path string, dest interface{}) error {
+	str, err := String(c.Do("JSON.GET", c.getKey(key), path))
+	if err != nil {
+		var redisErr *RedisError
+		if errors.As(err, &redisErr) && strings.Contains(redisErr.Message(), "does not exist") {
+			return ErrNil
+		}
+		return err
+	}
+	if strings.HasPrefix(path, "$") && str == "[]" {
+		return ErrNil
+	}
+	return c.unmarshal([]byte(str), dest)
+}
+
+/**
+Lua 脚本
+**/
+
+// Script 通过 NewScript 创建的Lua脚本。执行时优先使用 EVALSHA ，脚本未加载（NOSCRIPT）时自动使用 EVAL 执行并加载脚本。
+type Script struct {
+	c        *Cacher
+	keyCount int
+	script   *redis.Script
+}
+
+// NewScript 创建Lua脚本，keyCount 为脚本使用的键的数量，小于0时表示键的数量不固定。
+// Example:
+//
+// ```golang
+// script := c.NewScript(1, "return redis.call('GET', KEYS[1])")
+// reply, err := script.Run([]string{"name"})
+// ```
+func (c *Cacher) NewScript(keyCount int, src string) *Script {
+	return &Script{
+		c:        c,
+		keyCount: keyCount,
+		script:   redis.NewScript(-1, src),
+	}
+}
+
+// Hash 返回脚本的SHA1校验值
+func (s *Script) Hash() string {
+	return s.script.Hash()
+}
+
+// Run 执行脚本。keys 会自动加上键名前缀，在脚本中通过 KEYS 访问；args 在脚本中通过 ARGV 访问。
+func (s *Script) Run(keys []string, args ...interface{}) (interface{}, error) {
+	if s.keyCount >= 0 && len(keys) != s.keyCount {
+		return nil, fmt.Errorf("redisgo: script expects %d keys, got %d", s.keyCount, len(keys))
+	}
+	return s.c.evalScript(s.script, keys, args...)
+}
+
+// evalScript 执行键的数量不固定（keyCount为-1）的脚本，keys 会自动加上键名前缀
+func (c *Cacher) evalScript(script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	keysAndArgs := redis.Args{}.Add(len(keys))
+	for _, key := range keys {
+		keysAndArgs = keysAndArgs.Add(c.getKey(key))
+	}
+	keysAndArgs = keysAndArgs.Add(args...)
+	var conn redis.Conn
+	if len(keys) > 0 {
+		conn = c.getConn(c.getKey(keys[0]))
+	} else {
+		conn = c.track(c.pool.Get())
+	}
+	defer conn.Close()
+	reply, err := script.Do(conn, keysAndArgs...)
+	return reply, wrapError(err)
+}
+
+var setIfGreaterScript = redis.NewScript(-1, `
+local current = redis.call('GET', KEYS[1])
+if current and tonumber(ARGV[1]) <= tonumber(current) then
+	return 0
+end
+local ttl = redis.call('PTTL', KEYS[1])
+redis.call('SET', KEYS[1], ARGV[1])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// SetIfGreater 只有当 value 大于键中保存的整数值，或者键不存在时，才将键的值设为 value ，并保留原有的过期时间。
+// 比较和设置在Lua脚本中原子地完成，适用于保存最大序号等单调递增的值。返回值表示是否更新了键的值。
+func (c *Cacher) SetIfGreater(key string, value int64) (bool, error) {
+	return Bool(c.evalScript(setIfGreaterScript, []string{key}, value))
+}
+
+var incrCappedScript = redis.NewScript(-1, `
+local current = redis.call('GET', KEYS[1])
+local exists = current ~= false
+current = tonumber(current or '0')
+local value = current + tonumber(ARGV[1])
+local capped = 0
+if value > tonumber(ARGV[2]) then
+	value = tonumber(ARGV[2])
+	capped = 1
+end
+redis.call('INCRBY', KEYS[1], value - current)
+if not exists and tonumber(ARGV[3]) > 0 then
+	redis.call('EXPIRE', KEYS[1], ARGV[3])
+end
+return {value, capped}
+`)
+
+// IncrCapped 将键中保存的数字增加 amount ，但不会超过 max ，返回增加后的值，以及是否因超过 max 而被限制。
+// 键不存在时视为0，并设置过期时间 expire ，单位为秒，值为0时不过期；键已存在时保留原有的过期时间。
+// 检查和增加在Lua脚本中原子地完成，适用于配额计数等场景，避免先读取再判断再写入的竞态。
+func (c *Cacher) IncrCapped(key string, amount, max int64, expire int64) (newVal int64, capped bool, err error) {
+	values, err := redis.Int64s(c.evalScript(incrCappedScript, []string{key}, amount, max, expire))
+	if err != nil {
+		return 0, false, err
+	}
+	if len(values) != 2 {
+		return 0, false, fmt.Errorf("redisgo: unexpected number of values, got %d", len(values))
+	}
+	return values[0], values[1] == 1, nil
+}
+
+var promoteKeyScript = redis.NewScript(-1, `
+redis.call('RENAME', KEYS[1], KEYS[2])
+if tonumber(ARGV[1]) > 0 then
+	redis.call('EXPIRE', KEYS[2], ARGV[1])
+end
+return 1
+`)
+
+// PromoteKey 将 staging 重命名为 live 并设置过期时间，单位为秒，expire 为0时不过期。
+// 重命名和设置过期时间在Lua脚本中原子地完成，适用于先在临时键中构建好数据，再一次性替换正式键的场景，读取 live 的一方不会看到未构建完成的数据。
+// staging 不存在时返回错误。集群模式下两个键必须在同一个哈希槽中，参见 HashTag 。
+func (c *Cacher) PromoteKey(staging, live string, expire int64) error {
+	_, err := c.evalScript(promoteKeyScript, []string{staging, live}, expire)
+	return err
+}
+
+var pushAndTrimScript = redis.NewScript(-1, `
+local maxLen = tonumber(ARGV[1])
+redis.call('LPUSH', KEYS[1], unpack(ARGV, 2))
+local trimmed = redis.call('LRANGE', KEYS[1], maxLen, -1)
+redis.call('LTRIM', KEYS[1], 0, maxLen - 1)
+return trimmed
+`)
+
+// PushAndReturnTrimmed 将 values 依次插入到列表头部，并将列表裁剪为最多 maxLen 个元素，返回被裁剪掉的元素（从新到旧）。
+// 插入和裁剪在Lua脚本中原子地完成，适用于只保留最近若干条记录、同时需要归档被淘汰记录的场景，例如审计日志。
+func (c *Cacher) PushAndReturnTrimmed(key string, maxLen int, values ...interface{}) (trimmed []string, err error) {
+	if maxLen <= 0 {
+		return nil, fmt.Errorf("redisgo: maxLen must be positive, got %d", maxLen)
+	}
+	if len(values) == 0 {
+		return nil, errors.New("redisgo: no values to push")
+	}
+	args := redis.Args{}.Add(maxLen)
+	for _, v := range values {
+		value, err := c.encode(v)
+		if err != nil {
+			return nil, err
+		}
+		args = args.Add(value)
+	}
+	return redis.Strings(c.evalScript(pushAndTrimScript, []string{key}, args...))
+}
+
+/**
+服务器管理
+**/
+
+// SlowLogEntry 慢查询日志中的一条记录
+type SlowLogEntry struct {
+	ID             int64     // 日志的唯一标识
+	Timestamp      time.Time // 命令执行的时间
+	DurationMicros int64     // 命令执行的耗时，单位为微秒
+	Command        []string  // 命令及其参数
+	ClientAddr     string    // 客户端地址，需要redis 4.0及以上版本
+	ClientName     string    // 客户端名称，需要redis 4.0及以上版本
+}
+
+// SlowLogGet 返回最新的 count 条慢查询日志，count 小于0时返回全部日志。
+func (c *Cacher) SlowLogGet(count int) ([]*SlowLogEntry, error) {
+	return toSlowLogEntries(c.Do("SLOWLOG", "GET", count))
+}
+
+// SlowLogReset 清空慢查询日志
+func (c *Cacher) SlowLogReset() error {
+	_, err := c.Do("SLOWLOG", "RESET")
+	return err
+}
+
+// ClientInfo 由 ClientList 返回的一个客户端连接的信息
+type ClientInfo struct {
+	ID    int64             // 连接的唯一标识
+	Addr  string            // 客户端地址
+	Name  string            // 由 CLIENT SETNAME 设置的连接名称
+	Age   int64             // 连接已建立的时长，单位为秒
+	Idle  int64             // 连接的空闲时长，单位为秒
+	Flags string            // 连接的标志，例如 N 表示普通客户端， S 表示从节点
+	DB    int               // 当前使用的数据库
+	Cmd   string            // 最近一次执行的命令
+	Raw   map[string]string // CLIENT LIST 返回的所有字段，包括以上字段
+}
+
+// ClientList 返回连接到服务器的所有客户端的信息，可用于排查占用连接过多或长时间阻塞的客户端。
+func (c *Cacher) ClientList() ([]*ClientInfo, error) {
+	return toClientInfos(String(c.Do("CLIENT", "LIST")))
+}
+
+// ClientGetName 返回当前连接的名称，由 Options.ClientName 设置，没有设置名称时返回 ErrNil
+func (c *Cacher) ClientGetName() (string, error) {
+	return String(c.Do("CLIENT", "GETNAME"))
+}
+
+// ClientKill 关闭地址为 addr（ip:port）的客户端连接
+func (c *Cacher) ClientKill(addr string) error {
+	_, err := c.Do("CLIENT", "KILL", "ADDR", addr)
+	return err
+}
+
+// toClientInfos 解析 CLIENT LIST 的返回值，每行为一个客户端，由空格分隔的 key=value 组成
+func toClientInfos(reply string, err error) ([]*ClientInfo, error) {
+	if err != nil {
+		return nil, err
+	}
+	var clients []*ClientInfo
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		client := &ClientInfo{Raw: make(map[string]string)}
+		for _, field := range strings.Fields(line) {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			client.Raw[kv[0]] = kv[1]
+		}
+		client.ID, _ = strconv.ParseInt(client.Raw["id"], 10, 64)
+		client.Addr = client.Raw["addr"]
+		client.Name = client.Raw["name"]
+		client.Age, _ = strconv.ParseInt(client.Raw["age"], 10, 64)
+		client.Idle, _ = strconv.ParseInt(client.Raw["idle"], 10, 64)
+		client.Flags = client.Raw["flags"]
+		client.DB, _ = strconv.Atoi(client.Raw["db"])
+		client.Cmd = client.Raw["cmd"]
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// Time 返回redis服务器的当前时间。多个实例使用同一个时间来源时，可以避免各实例时钟不一致的问题。
+func (c *Cacher) Time() (time.Time, error) {
+	values, err := redis.Int64s(c.Do("TIME"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(values) != 2 {
+		return time.Time{}, fmt.Errorf("redisgo: unexpected number of values, got %d", len(values))
+	}
+	return time.Unix(values[0], values[1]*int64(time.Microsecond)), nil
+}
+
+// CommandCount 返回服务器支持的命令总数
+func (c *Cacher) CommandCount() (int64, error) {
+	return Int64(c.Do("COMMAND", "COUNT"))
+}
+
+// CommandExists 通过 COMMAND INFO 检查服务器是否支持名为 name 的命令，可以在使用 GETDEL 等较新的命令前检测服务器的版本是否支持，
+// 也可以用于在执行前校验命令白名单中的命令。命令名不区分大小写。
+func (c *Cacher) CommandExists(name string) (bool, error) {
+	values, err := redis.Values(c.Do("COMMAND", "INFO", name))
+	if err != nil {
+		return false, err
+	}
+	// 命令不存在时，对应的元素为nil
+	return len(values) == 1 && values[0] != nil, nil
+}
+
+// MemoryDoctor 返回redis对内存使用情况的诊断建议，内容为便于阅读的文本
+func (c *Cacher) MemoryDoctor() (string, error) {
+	return String(c.Do("MEMORY", "DOCTOR"))
+}
+
+// MemoryStats 返回服务器的内存使用统计，例如 peak.allocated 、 total.allocated 、 dataset.bytes 等。
+// 整数值的类型为int64，字符串值的类型为string，浮点数等以字符串返回，db.0 等嵌套的统计为 map[string]interface{} 。
+func (c *Cacher) MemoryStats() (map[string]interface{}, error) {
+	values, err := redis.Values(c.Do("MEMORY", "STATS"))
+	if err != nil {
+		return nil, err
+	}
+	return toMemoryStats(values)
+}
+
+func toMemoryStats(values []interface{}) (map[string]interface{}, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("redisgo: MemoryStats expects even number of values result")
+	}
+	stats := make(map[string]interface{}, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		name, err := redis.String(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		switch v := values[i+1].(type) {
+		case []byte:
+			stats[name] = string(v)
+		case []interface{}:
+			if stats[name], err = toMemoryStats(v); err != nil {
+				return nil, err
+			}
+		default:
+			stats[name] = v
+		}
+	}
+	return stats, nil
+}
+
+// Role 返回当前连接的节点的角色，值为 master 、 slave 或 sentinel ，可用于确认从节点确实处于复制状态。
+// detail 为 ROLE 命令返回的其余部分，类型为 []interface{} ，内容与角色有关：
+// master : 复制偏移量，以及由各从节点的地址、端口、复制偏移量组成的数组。
+// slave : 主节点的地址、端口、复制状态（例如 connected ）、已接收数据的偏移量。
+// sentinel : 所监控的主节点名称组成的数组。
+func (c *Cacher) Role() (role string, detail interface{}, err error) {
+	values, err := redis.Values(c.Do("ROLE"))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(values) == 0 {
+		return "", nil, errors.New("redisgo: unexpected empty ROLE reply")
+	}
+	role, err = redis.String(values[0], nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return role, values[1:], nil
+}
+
+// LastSave 返回最近一次成功将数据保存到磁盘上的时间
+func (c *Cacher) LastSave() (time.Time, error) {
+	sec, err := Int64(c.Do("LASTSAVE"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// BgSave 在后台异步保存当前数据库的数据到磁盘，返回redis的状态回复，例如 Background saving started 。
+// 已有后台保存或AOF重写在执行时会返回错误。
+func (c *Cacher) BgSave() (string, error) {
+	return String(c.Do("BGSAVE"))
+}
+
+// BgRewriteAOF 在后台异步执行AOF文件重写，返回redis的状态回复，例如 Background append only file rewriting started 。
+func (c *Cacher) BgRewriteAOF() (string, error) {
+	return String(c.Do("BGREWRITEAOF"))
+}
+
+// Wait 在同一个连接上依次执行 fn 和 WAIT 命令，阻塞直到 fn 中的写命令被至少 numReplicas 个从节点确认，或者等待超时，返回确认了写命令的从节点数量。
+// WAIT 只对同一个连接上之前执行的写命令有效，所以写命令需要通过 fn 的 conn 执行。fn 返回错误时不执行 WAIT ，直接返回该错误。
+// 最多阻塞 timeout 时长，timeout 为0时表示一直阻塞。
+func (c *Cacher) Wait(fn func(conn redis.Conn) error, numReplicas int, timeout time.Duration) (n int64, err error) {
+	err = c.WithConn(func(conn redis.Conn) error {
+		if err := fn(conn); err != nil {
+			return err
+		}
+		reply, err := conn.Do("WAIT", numReplicas, int64(timeout/time.Millisecond))
+		n, err = Int64(reply, wrapError(err))
+		return err
+	})
+	return n, err
+}
+
+// ErrDangerousCommandDisabled 未通过 Options.AllowDangerousCommands 启用时，执行 FLUSHALL 、 FLUSHDB 、 KEYS 命令返回该错误
+var ErrDangerousCommandDisabled = errors.New("redisgo: dangerous commands are disabled, set Options.AllowDangerousCommands to enable them")
+
+// dangerousCommands 可能清空数据或长时间阻塞服务的命令
+var dangerousCommands = []string{"FLUSHALL", "FLUSHDB", "KEYS"}
+
+// checkCommand 检查是否允许执行命令，所有直接执行命令的入口（ Do 、 DoTimeout 、 DoReadOnly 、 Pipeline 、 BatchWriter ）都需要调用
+func (c *Cacher) checkCommand(commandName string) error {
+	if !c.allowDangerous && isDangerousCommand(commandName) {
+		return ErrDangerousCommandDisabled
+	}
+	return nil
+}
+
+func isDangerousCommand(commandName string) bool {
+	for _, name := range dangerousCommands {
+		if strings.EqualFold(commandName, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrDebugDisabled 未通过 Options.AllowDebug 启用DEBUG命令时返回该错误
+var ErrDebugDisabled = errors.New("redisgo: DEBUG commands are disabled, set Options.AllowDebug to enable them")
+
+// DebugSleep 使redis服务器阻塞指定的时长，期间不处理任何命令，可用于测试超时和重试逻辑。
+// 需要通过 Options.AllowDebug 启用。
+func (c *Cacher) DebugSleep(d time.Duration) error {
+	if !c.allowDebug {
+		return ErrDebugDisabled
+	}
+	_, err := c.Do("DEBUG", "SLEEP", d.Seconds())
+	return err
+}
+
+// DebugObject 返回键的调试信息，例如编码、序列化后的长度等。需要通过 Options.AllowDebug 启用。
+func (c *Cacher) DebugObject(key string) (string, error) {
+	if !c.allowDebug {
+		return "", ErrDebugDisabled
+	}
+	return String(c.Do("DEBUG", "OBJECT", c.getKey(key)))
+}
+
+func toSlowLogEntries(reply interface{}, err error) ([]*SlowLogEntry, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*SlowLogEntry, len(values))
+	for i := range values {
+		p, ok := values[i].([]interface{})
+		if !ok || len(p) < 4 {
+			return nil, fmt.Errorf("redisgo: unexpected slowlog entry, got type %T", values[i])
+		}
+		entry := &SlowLogEntry{}
+		if entry.ID, err = redis.Int64(p[0], nil); err != nil {
+			return nil, err
+		}
+		timestamp, err := redis.Int64(p[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		entry.Timestamp = time.Unix(timestamp, 0)
+		if entry.DurationMicros, err = redis.Int64(p[2], nil); err != nil {
+			return nil, err
+		}
+		if entry.Command, err = redis.Strings(p[3], nil); err != nil {
+			return nil, err
+		}
+		if len(p) >= 6 {
+			if entry.ClientAddr, err = redis.String(p[4], nil); err != nil {
+				return nil, err
+			}
+			if entry.ClientName, err = redis.String(p[5], nil); err != nil {
+				return nil, err
+			}
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
 // getKey 将健名加上指定的前缀。
 func (c *Cacher) getKey(key string) string {
 	return c.prefix + key
 }
 
+// getTagKey 返回标签对应的集合的键名
+func (c *Cacher) getTagKey(tag string) string {
+	return c.getKey("tag:" + tag)
+}
+
 // encode 序列化要保存的值
 func (c *Cacher) encode(val interface{}) (interface{}, error) {
 	var value interface{}
@@ -791,13 +2773,45 @@ func (c *Cacher) encode(val interface{}) (interface{}, error) {
 	return value, nil
 }
 
-// decode 反序列化保存的struct对象
+// decode 反序列化保存的值，与 Decode 相同，基本类型直接转换，其他类型反序列化
 func (c *Cacher) decode(reply interface{}, err error, val interface{}) error {
-	str, err := String(reply, err)
 	if err != nil {
 		return err
 	}
-	return c.unmarshal([]byte(str), val)
+	return c.Decode(reply, val)
+}
+
+// MarshalFunc 和 UnmarshalFunc 是未设置 Options.Marshal 、 Options.Unmarshal 时使用的json序列化方法，默认使用标准库。
+// 每次序列化时才读取，可以在程序初始化时替换为 jsoniter 等更快的实现，对已经创建的实例同样有效：
+//
+// ```golang
+// var json = jsoniter.ConfigCompatibleWithStandardLibrary
+// redisgo.MarshalFunc = json.Marshal
+// redisgo.UnmarshalFunc = json.Unmarshal
+// ```
+var (
+	MarshalFunc   = json.Marshal
+	UnmarshalFunc = json.Unmarshal
+)
+
+func defaultMarshal(v interface{}) ([]byte, error) {
+	return MarshalFunc(v)
+}
+
+func defaultUnmarshal(data []byte, v interface{}) error {
+	return UnmarshalFunc(data, v)
+}
+
+// marshalWithoutHTMLEscape 与json.Marshal相同，但不转义HTML字符
+func marshalWithoutHTMLEscape(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	// Encode 会在末尾加上换行符
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
 }
 
 // closePool 程序进程退出时关闭连接池
@@ -808,11 +2822,25 @@ func (c *Cacher) closePool() {
 	signal.Notify(ch, syscall.SIGKILL)
 	go func() {
 		<-ch
-		c.pool.Close()
+		c.closeAll()
 		os.Exit(0)
 	}()
 }
 
+// closeAll 关闭所有连接池
+func (c *Cacher) closeAll() {
+	if c.tracker != nil {
+		c.tracker.closeConn()
+	}
+	c.pool.Close()
+	for _, pool := range c.replicas.pools {
+		pool.Close()
+	}
+	if c.cluster != nil {
+		c.cluster.close()
+	}
+}
+
 // init 注册到cache
 // func init() {
 // 	cache.Register("redis", &Cacher{})
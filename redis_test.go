@@ -1,9 +1,23 @@
 package redisgo
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/gomodule/redigo/redis"
 )
 
 type User struct {
@@ -31,6 +45,69 @@ func Equal(t *testing.T, expected, actual interface{}) {
 	}
 }
 
+// fakeConn 不依赖redis服务的连接，对所有命令都返回OK
+type fakeConn struct {
+	closed bool
+}
+
+func (fc *fakeConn) Close() error {
+	fc.closed = true
+	return nil
+}
+
+func (fc *fakeConn) Err() error {
+	return nil
+}
+
+func (fc *fakeConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return "OK", nil
+}
+
+func (fc *fakeConn) Send(commandName string, args ...interface{}) error {
+	return nil
+}
+
+func (fc *fakeConn) Flush() error {
+	return nil
+}
+
+func (fc *fakeConn) Receive() (interface{}, error) {
+	return "OK", nil
+}
+
+// scriptConn 由 do 决定命令返回值的连接，sent 记录通过Send发送的命令
+type scriptConn struct {
+	fakeConn
+	sent []string
+	do   func(conn *scriptConn, commandName string, args ...interface{}) (interface{}, error)
+}
+
+func (sc *scriptConn) Send(commandName string, args ...interface{}) error {
+	sc.sent = append(sc.sent, commandName)
+	return nil
+}
+
+func (sc *scriptConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName == "" {
+		// 连接归还到连接池时会调用 Do("")
+		return nil, nil
+	}
+	return sc.do(sc, commandName, args...)
+}
+
+// getFakeCacher 创建一个使用fakeConn的实例，dials记录建立连接的次数
+func getFakeCacher(options Options, dials *int) *Cacher {
+	c, err := New(options)
+	if err != nil {
+		panic(err)
+	}
+	c.pool.Dial = func() (redis.Conn, error) {
+		*dials++
+		return &fakeConn{}, nil
+	}
+	return c
+}
+
 func getCacher() *Cacher {
 	c, err := New(
 		Options{
@@ -81,6 +158,424 @@ func TestGetSet(t *testing.T) {
 	Equal(t, 23, valUser.Age)
 }
 
+func TestLCS(t *testing.T) {
+	c := getCacher()
+	err := c.Set("key1", "ohmytext", 30)
+	NoError(t, err)
+	err = c.Set("key2", "mynewtext", 30)
+	NoError(t, err)
+
+	lcs, err := c.LCS("key1", "key2")
+	NoError(t, err)
+	Equal(t, "mytext", lcs)
+	n, err := c.LCSLen("key1", "key2")
+	NoError(t, err)
+	Equal(t, int64(6), n)
+}
+
+func TestJSON(t *testing.T) {
+	c := getCacher()
+	c.Del("doc")
+	err := c.JSONSet("doc", "$", &User{Name: "corel", Age: 23})
+	if errorCode(err) == "ERR" && strings.Contains(err.Error(), "unknown command") {
+		t.Skip("RedisJSON module is not loaded")
+	}
+	NoError(t, err)
+	err = c.JSONSet("doc", "$.Age", 24)
+	NoError(t, err)
+
+	var user User
+	err = c.JSONGet("doc", ".", &user)
+	NoError(t, err)
+	Equal(t, User{Name: "corel", Age: 24}, user)
+	var ages []int
+	err = c.JSONGet("doc", "$.Age", &ages)
+	NoError(t, err)
+	Equal(t, []int{24}, ages)
+
+	err = c.JSONGet("doc", "$.Email", &ages)
+	Equal(t, ErrNil, err)
+	err = c.JSONGet("doc", ".Email", &ages)
+	Equal(t, ErrNil, err)
+	err = c.JSONGet("nodoc", "$", &ages)
+	Equal(t, ErrNil, err)
+}
+
+func TestDisableHTMLEscape(t *testing.T) {
+	c, err := New(Options{Prefix: "zengate_", DisableHTMLEscape: true})
+	NoError(t, err)
+	user := &User{
+		Name: "<b>corel</b> & co",
+		Age:  23,
+	}
+	err = c.Set("user", user, 30)
+	NoError(t, err)
+	raw, err := c.GetString("user")
+	NoError(t, err)
+	Equal(t, `{"Name":"<b>corel</b> & co","Age":23}`, raw)
+	valUser := &User{}
+	err = c.GetObject("user", valUser)
+	NoError(t, err)
+	Equal(t, user.Name, valUser.Name)
+}
+
+func TestCustomMarshal(t *testing.T) {
+	marshaled := 0
+	c, err := New(Options{
+		Prefix: "zengate_",
+		Marshal: func(v interface{}) ([]byte, error) {
+			marshaled++
+			return json.Marshal(v)
+		},
+	})
+	NoError(t, err)
+	err = c.Set("user", &User{Name: "corel"}, 30)
+	NoError(t, err)
+	Equal(t, 1, marshaled)
+}
+
+func TestMarshalFunc(t *testing.T) {
+	c := getCacher()
+	marshaled, unmarshaled := 0, 0
+	defer func(marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) {
+		MarshalFunc, UnmarshalFunc = marshal, unmarshal
+	}(MarshalFunc, UnmarshalFunc)
+	MarshalFunc = func(v interface{}) ([]byte, error) {
+		marshaled++
+		return json.Marshal(v)
+	}
+	UnmarshalFunc = func(data []byte, v interface{}) error {
+		unmarshaled++
+		return json.Unmarshal(data, v)
+	}
+
+	NoError(t, c.Set("user", &User{Name: "corel"}, 30))
+	var user User
+	NoError(t, c.GetObject("user", &user))
+	Equal(t, "corel", user.Name)
+	Equal(t, 1, marshaled)
+	Equal(t, 1, unmarshaled)
+}
+
+func TestHooks(t *testing.T) {
+	var mu sync.Mutex
+	commands := make(map[string]time.Duration)
+	serializations := make(map[string]time.Duration)
+	c, err := New(Options{
+		Prefix: "zengate_",
+		Marshal: func(v interface{}) ([]byte, error) {
+			time.Sleep(20 * time.Millisecond)
+			return json.Marshal(v)
+		},
+		Unmarshal: func(data []byte, v interface{}) error {
+			time.Sleep(20 * time.Millisecond)
+			return json.Unmarshal(data, v)
+		},
+		CommandHook: func(commandName string, elapsed time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			commands[commandName] += elapsed
+		},
+		SerializationHook: func(op string, elapsed time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			serializations[op] += elapsed
+		},
+	})
+	NoError(t, err)
+	NoError(t, c.Set("user", &User{Name: "corel"}, 30))
+	var user User
+	NoError(t, c.GetObject("user", &user))
+	Equal(t, "corel", user.Name)
+	_, err = c.DoTimeout(time.Second, "PING")
+	NoError(t, err)
+	_, err = c.DoReadOnly("ECHO", "hi")
+	NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	Equal(t, true, serializations["marshal"] >= 20*time.Millisecond)
+	Equal(t, true, serializations["unmarshal"] >= 20*time.Millisecond)
+	Equal(t, true, commands["SETEX"] > 0 && commands["SETEX"] < 20*time.Millisecond)
+	Equal(t, true, commands["GET"] > 0 && commands["GET"] < 20*time.Millisecond)
+	Equal(t, true, commands["PING"] > 0)
+	Equal(t, true, commands["ECHO"] > 0)
+}
+
+func TestBytes(t *testing.T) {
+	c := getCacher()
+	data := []byte{0, 1, 0x7f, 0x80, 0xff, '"', '\\', 0}
+	NoError(t, c.SetBytes("bytes", data, 30))
+	got, err := c.GetBytes("bytes")
+	NoError(t, err)
+	Equal(t, data, got)
+
+	c.Del("missing")
+	_, err = c.GetBytes("missing")
+	Equal(t, ErrNil, err)
+}
+
+func TestMGetT(t *testing.T) {
+	c := getCacher()
+	c.Del("missing")
+	NoError(t, c.Set("user:1", &User{Name: "corel", Age: 23}, 30))
+	NoError(t, c.Set("user:2", &User{Name: "zengate", Age: 18}, 30))
+	users, err := MGetT[User](c, "user:2", "missing", "user:1")
+	NoError(t, err)
+	Equal(t, []User{{Name: "zengate", Age: 18}, {}, {Name: "corel", Age: 23}}, users)
+
+	NoError(t, c.Set("age", 23, 30))
+	ages, err := MGetT[int](c, "age", "missing")
+	NoError(t, err)
+	Equal(t, []int{23, 0}, ages)
+
+	NoError(t, c.Set("name", "corel", 30))
+	_, err = MGetT[int](c, "age", "name")
+	Equal(t, true, err != nil && strings.Contains(err.Error(), "name"))
+}
+
+func TestBitField(t *testing.T) {
+	c := getCacher()
+	c.Del("counters")
+	values, err := c.BitField("counters",
+		BitFieldSet("u8", 0, 200),
+		BitFieldSet("i16", 8, -5),
+		BitFieldIncrBy("u8", 0, 10),
+		BitFieldIncrBy("i16", 8, 3),
+	)
+	NoError(t, err)
+	Equal(t, []int64{0, 0, 210, -2}, values)
+
+	// u8 溢出时回绕
+	values, err = c.BitField("counters", BitFieldIncrBy("u8", 0, 50), BitFieldGet("i16", 8), BitFieldGet("u8", 24))
+	NoError(t, err)
+	Equal(t, []int64{4, -2, 0}, values)
+
+	_, err = c.BitField("counters", BitFieldOp{Op: "DEL", Type: "u8"})
+	Error(t, err)
+}
+
+func TestGetOr(t *testing.T) {
+	var buf bytes.Buffer
+	c, err := New(Options{Prefix: "zengate_", Logger: log.New(&buf, "", 0)})
+	NoError(t, err)
+	c.Del("missing")
+	err = c.Set("name", "corel", 30)
+	NoError(t, err)
+	err = c.Set("age", 23, 30)
+	NoError(t, err)
+
+	Equal(t, "corel", c.GetStringOr("name", "nobody"))
+	Equal(t, "nobody", c.GetStringOr("missing", "nobody"))
+	Equal(t, 23, c.GetIntOr("age", 18))
+	Equal(t, 18, c.GetIntOr("missing", 18))
+	Equal(t, "", buf.String())
+
+	// 其他错误也返回默认值，并输出日志
+	Equal(t, 18, c.GetIntOr("name", 18))
+	Equal(t, true, strings.Contains(buf.String(), "get name failed"))
+}
+
+func TestGetObjectRoundTrip(t *testing.T) {
+	var err error
+	c := getCacher()
+
+	err = c.Set("age", 23, 30)
+	NoError(t, err)
+	var age int
+	err = c.GetObject("age", &age)
+	NoError(t, err)
+	Equal(t, 23, age)
+
+	err = c.Set("name", "corel", 30)
+	NoError(t, err)
+	var name string
+	err = c.GetObject("name", &name)
+	NoError(t, err)
+	Equal(t, "corel", name)
+
+	err = c.Set("subscribe", true, 30)
+	NoError(t, err)
+	var subscribe bool
+	err = c.GetObject("subscribe", &subscribe)
+	NoError(t, err)
+	Equal(t, true, subscribe)
+
+	err = c.Set("user", &User{Name: "corel", Age: 23}, 30)
+	NoError(t, err)
+	var user User
+	err = c.GetObject("user", &user)
+	NoError(t, err)
+	Equal(t, User{Name: "corel", Age: 23}, user)
+}
+
+func TestDecode(t *testing.T) {
+	c := getCacher()
+	var s string
+	err := c.Decode([]byte("corel"), &s)
+	NoError(t, err)
+	Equal(t, "corel", s)
+	var b []byte
+	err = c.Decode([]byte("corel"), &b)
+	NoError(t, err)
+	Equal(t, []byte("corel"), b)
+	var i int
+	err = c.Decode(int64(23), &i)
+	NoError(t, err)
+	Equal(t, 23, i)
+	var i64 int64
+	err = c.Decode([]byte("23"), &i64)
+	NoError(t, err)
+	Equal(t, int64(23), i64)
+	var f float64
+	err = c.Decode([]byte("1.5"), &f)
+	NoError(t, err)
+	Equal(t, 1.5, f)
+	var ok bool
+	err = c.Decode(int64(1), &ok)
+	NoError(t, err)
+	Equal(t, true, ok)
+
+	var user User
+	err = c.Decode([]byte(`{"Name":"corel","Age":23}`), &user)
+	NoError(t, err)
+	Equal(t, User{Name: "corel", Age: 23}, user)
+	var m map[string]int
+	err = c.Decode([]byte(`{"a":1}`), &m)
+	NoError(t, err)
+	Equal(t, map[string]int{"a": 1}, m)
+	var list []string
+	err = c.Decode([]byte(`["a","b"]`), &list)
+	NoError(t, err)
+	Equal(t, []string{"a", "b"}, list)
+
+	err = c.Decode(nil, &s)
+	Equal(t, ErrNil, err)
+}
+
+func TestGetAny(t *testing.T) {
+	var err error
+	c := getCacher()
+	for _, key := range []string{"any:string", "any:list", "any:set", "any:hash", "any:zset", "any:none"} {
+		c.Del(key)
+	}
+	NoError(t, c.Set("any:string", "corel", 30))
+	NoError(t, c.RPush("any:list", "a"))
+	NoError(t, c.RPush("any:list", "b"))
+	_, err = c.Do("SADD", c.getKey("any:set"), "a")
+	NoError(t, err)
+	_, err = c.HSet("any:hash", "name", "corel")
+	NoError(t, err)
+	_, err = c.ZAdd("any:zset", 82, "corel")
+	NoError(t, err)
+
+	for _, test := range []struct {
+		key      string
+		expected interface{}
+	}{
+		{"any:string", "corel"},
+		{"any:list", []string{"a", "b"}},
+		{"any:set", []string{"a"}},
+		{"any:hash", map[string]string{"name": "corel"}},
+		{"any:zset", []ZMember{{Member: "corel", Score: 82}}},
+	} {
+		val, err := c.GetAny(test.key)
+		NoError(t, err)
+		Equal(t, test.expected, val)
+	}
+	_, err = c.GetAny("any:none")
+	Equal(t, ErrNil, err)
+}
+
+func TestGetWithTTL(t *testing.T) {
+	c := getCacher()
+	err := c.Set("user", &User{Name: "corel", Age: 23}, 60)
+	NoError(t, err)
+	var user User
+	ttl, err := c.GetWithTTL("user", &user)
+	NoError(t, err)
+	Equal(t, "corel", user.Name)
+	Equal(t, true, ttl > 59*time.Second && ttl <= 60*time.Second)
+
+	err = c.Set("name", "corel", 0)
+	NoError(t, err)
+	var name string
+	ttl, err = c.GetWithTTL("name", &name)
+	NoError(t, err)
+	Equal(t, "corel", name)
+	Equal(t, time.Duration(-1), ttl)
+
+	c.Del("missing")
+	_, err = c.GetWithTTL("missing", &name)
+	Equal(t, ErrNil, err)
+}
+
+func TestStream(t *testing.T) {
+	c := getCacher()
+	payload := make([]byte, 1<<20)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	err := c.SetFromReader("blob", bytes.NewReader(payload), 60)
+	NoError(t, err)
+	ttl, err := c.TTL("blob")
+	NoError(t, err)
+	Equal(t, true, ttl > 0)
+
+	var buf bytes.Buffer
+	n, err := c.GetToWriter("blob", &buf)
+	NoError(t, err)
+	Equal(t, int64(len(payload)), n)
+	Equal(t, true, bytes.Equal(payload, buf.Bytes()))
+}
+
+func TestBulkSet(t *testing.T) {
+	c := getCacher()
+	items := make(map[string]interface{})
+	args := redis.Args{}
+	for i := 0; i < 10000; i++ {
+		key := "bulk:" + strconv.Itoa(i)
+		items[key] = i
+		args = args.Add(c.getKey(key))
+	}
+	err := c.BulkSet(items, 30, 500)
+	NoError(t, err)
+	n, err := Int(c.Do("EXISTS", args...))
+	NoError(t, err)
+	Equal(t, 10000, n)
+	val, err := c.GetInt("bulk:9999")
+	NoError(t, err)
+	Equal(t, 9999, val)
+}
+
+func TestTags(t *testing.T) {
+	var err error
+	c := getCacher()
+	err = c.SetWithTags("post:1", "a", 30, "user:42")
+	NoError(t, err)
+	err = c.SetWithTags("post:2", "b", 30, "user:42", "hot")
+	NoError(t, err)
+	err = c.SetWithTags("post:3", "c", 30, "user:43", "hot")
+	NoError(t, err)
+
+	n, err := c.InvalidateTag("user:42")
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	for key, exists := range map[string]bool{"post:1": false, "post:2": false, "post:3": true} {
+		ok, err := c.Exists(key)
+		NoError(t, err)
+		Equal(t, exists, ok)
+	}
+	n, err = c.InvalidateTag("user:42")
+	NoError(t, err)
+	Equal(t, int64(0), n)
+
+	// 标签集合的类型错误时，事务中 SADD 的错误需要返回
+	NoError(t, c.Set("tag:broken", "x", 30))
+	err = c.SetWithTags("post:4", "d", 30, "broken")
+	Equal(t, true, err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE"))
+}
+
 func TestIncrDecr(t *testing.T) {
 	var err error
 	c := getCacher()
@@ -100,6 +595,77 @@ func TestIncrDecr(t *testing.T) {
 	val, err = c.DecrBy("seq", 5)
 	NoError(t, err)
 	Equal(t, int64(1), val)
+
+	// 超过32位整数范围的增量
+	val, err = c.IncrBy("seq", 1<<40)
+	NoError(t, err)
+	Equal(t, int64(1<<40+1), val)
+	val, err = c.DecrBy("seq", 1<<40)
+	NoError(t, err)
+	Equal(t, int64(1), val)
+}
+
+func TestGetCached(t *testing.T) {
+	c, err := New(Options{
+		Prefix:            "zengate_",
+		LocalCacheSize:    10,
+		InvalidateChannel: "zengate_invalidate",
+	})
+	NoError(t, err)
+	time.Sleep(100 * time.Millisecond) // 等待订阅失效通知
+
+	err = c.Set("user", &User{Name: "corel", Age: 23}, 30)
+	NoError(t, err)
+	user := &User{}
+	err = c.GetCached("user", user, 30)
+	NoError(t, err)
+	Equal(t, "corel", user.Name)
+
+	// 键已从redis删除，但仍可从进程内缓存读取
+	c.Del("user")
+	user = &User{}
+	err = c.GetCached("user", user, 30)
+	NoError(t, err)
+	Equal(t, "corel", user.Name)
+
+	// 收到失效通知后删除进程内缓存
+	_, err = c.Publish("zengate_invalidate", c.getKey("user"))
+	NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	err = c.GetCached("user", user, 30)
+	Equal(t, ErrNil, err)
+
+	// 基础类型与 GetObject 一样直接读取，未命中和命中缓存时结果相同
+	NoError(t, c.Set("name", "corel", 30))
+	NoError(t, c.Set("age", 23, 30))
+	for i := 0; i < 2; i++ {
+		var name string
+		NoError(t, c.GetCached("name", &name, 30))
+		Equal(t, "corel", name)
+		var age int
+		NoError(t, c.GetCached("age", &age, 30))
+		Equal(t, 23, age)
+	}
+}
+
+func TestLocalCache(t *testing.T) {
+	lc := newLocalCache(2)
+	lc.set("a", "1", time.Minute)
+	lc.set("b", "2", time.Minute)
+	lc.get("a")
+	lc.set("c", "3", time.Minute)
+	_, ok := lc.get("b")
+	Equal(t, false, ok)
+	val, ok := lc.get("a")
+	Equal(t, true, ok)
+	Equal(t, "1", val)
+
+	lc.set("d", "4", -time.Second)
+	_, ok = lc.get("d")
+	Equal(t, false, ok)
+	lc.del("a")
+	_, ok = lc.get("a")
+	Equal(t, false, ok)
 }
 
 func TestExpire(t *testing.T) {
@@ -114,28 +680,1913 @@ func TestExpire(t *testing.T) {
 	Error(t, err)
 }
 
-func TestHash(t *testing.T) {
-	var err error
+func TestSetKeepTTL(t *testing.T) {
 	c := getCacher()
-	m := make(map[string]interface{})
-	m["name"] = "corel"
-	m["age"] = 23
-	err = c.HMSet("huser", m, 10)
+	err := c.Set("name", "corel", 60)
+	NoError(t, err)
+	err = c.SetKeepTTL("name", "zen")
+	NoError(t, err)
+	val, err := c.GetString("name")
 	NoError(t, err)
+	Equal(t, "zen", val)
+	ttl, err := c.TTL("name")
+	NoError(t, err)
+	Equal(t, true, ttl > 0 && ttl <= 60)
+}
 
-	age, err := c.HGetInt("huser", "age")
+func TestMissingKeys(t *testing.T) {
+	c := getCacher()
+	c.Del("missing1")
+	c.Del("missing2")
+	err := c.Set("present1", "value", 30)
 	NoError(t, err)
-	Equal(t, m["age"], age)
+	err = c.Set("present2", "value", 30)
+	NoError(t, err)
+
+	missing, err := c.MissingKeys("missing2", "present1", "missing1", "present2")
+	NoError(t, err)
+	Equal(t, []string{"missing2", "missing1"}, missing)
+	missing, err = c.MissingKeys("present1")
+	NoError(t, err)
+	Equal(t, 0, len(missing))
 }
 
-func TestSortedSet(t *testing.T) {
+func TestMove(t *testing.T) {
+	c := getCacher()
+	target, err := New(Options{Prefix: "zengate_", Db: 1})
+	NoError(t, err)
+	target.Del("name")
+	err = c.Set("name", "corel", 0)
+	NoError(t, err)
+
+	moved, err := c.Move("name", 1)
+	NoError(t, err)
+	Equal(t, true, moved)
+	exists, err := c.Exists("name")
+	NoError(t, err)
+	Equal(t, false, exists)
+	val, err := target.GetString("name")
+	NoError(t, err)
+	Equal(t, "corel", val)
+
+	// 目标数据库中已存在同名的键时不移动
+	err = c.Set("name", "zen", 0)
+	NoError(t, err)
+	moved, err = c.Move("name", 1)
+	NoError(t, err)
+	Equal(t, false, moved)
+}
+
+func TestExistsMap(t *testing.T) {
+	c := getCacher()
+	c.Del("missing1")
+	err := c.Set("present1", "value", 30)
+	NoError(t, err)
+
+	exists, err := c.ExistsMap("present1", "missing1")
+	NoError(t, err)
+	Equal(t, map[string]bool{"present1": true, "missing1": false}, exists)
+}
+
+func TestScanChan(t *testing.T) {
+	c := getCacher()
+	expected := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		key := "scan:" + strconv.Itoa(i)
+		NoError(t, c.Set(key, i, 30))
+		expected[key] = true
+	}
+
+	keys, errc := c.ScanChan(context.Background(), "scan:*", 5)
+	found := make(map[string]bool)
+	for key := range keys {
+		found[key] = true
+	}
+	NoError(t, <-errc)
+	Equal(t, expected, found)
+
+	// 取消后后台协程退出
+	ctx, cancel := context.WithCancel(context.Background())
+	keys, errc = c.ScanChan(ctx, "scan:*", 5)
+	<-keys
+	cancel()
+	for range keys {
+	}
+	Equal(t, context.Canceled, <-errc)
+}
+
+func TestTTLMulti(t *testing.T) {
+	c := getCacher()
+	c.Del("missing")
+	err := c.Set("withttl", "value", 60)
+	NoError(t, err)
+	err = c.Set("nottl", "value", 0)
+	NoError(t, err)
+
+	ttls, err := c.TTLMulti("withttl", "nottl", "missing")
+	NoError(t, err)
+	Equal(t, 3, len(ttls))
+	Equal(t, true, ttls["withttl"] > 0 && ttls["withttl"] <= 60)
+	Equal(t, int64(-1), ttls["nottl"])
+	Equal(t, int64(-2), ttls["missing"])
+}
+
+func TestExpireOpts(t *testing.T) {
 	var err error
 	c := getCacher()
-	_, err = c.ZAdd("scores", 82, "corel")
+	err = c.Set("name", "corel", 0)
 	NoError(t, err)
-	_, err = c.ZAdd("scores", 86, "zen")
+
+	// NX 只在没有过期时间时设置
+	ok, err := c.ExpireOpts("name", 100, "NX")
 	NoError(t, err)
-	score, err := c.ZScore("scores", "corel")
+	Equal(t, true, ok)
+	ok, err = c.ExpireOpts("name", 200, "NX")
 	NoError(t, err)
-	Equal(t, int64(82), score)
+	Equal(t, false, ok)
+
+	// GT 不会缩短过期时间
+	ok, err = c.ExpireOpts("name", 50, "GT")
+	NoError(t, err)
+	Equal(t, false, ok)
+	ttl, err := c.TTL("name")
+	NoError(t, err)
+	Equal(t, true, ttl > 50)
+	ok, err = c.ExpireOpts("name", 300, "GT")
+	NoError(t, err)
+	Equal(t, true, ok)
+	ttl, err = c.TTL("name")
+	NoError(t, err)
+	Equal(t, true, ttl > 200)
+
+	_, err = c.ExpireOpts("name", 300, "GTE")
+	Error(t, err)
+}
+
+func TestHash(t *testing.T) {
+	var err error
+	c := getCacher()
+	m := make(map[string]interface{})
+	m["name"] = "corel"
+	m["age"] = 23
+	err = c.HMSet("huser", m, 10)
+	NoError(t, err)
+
+	age, err := c.HGetInt("huser", "age")
+	NoError(t, err)
+	Equal(t, m["age"], age)
+}
+
+func TestSetStruct(t *testing.T) {
+	type Profile struct {
+		Name    string  `redis:"name"`
+		Age     int     `redis:"age"`
+		Score   float64 `redis:"score"`
+		Active  bool    `redis:"active"`
+		Balance int64   `redis:"balance"`
+	}
+	c := getCacher()
+	c.Del("profile")
+	c.HSet("profile", "stale", "x")
+	profile := Profile{Name: "corel", Age: 23, Score: 9.5, Active: true, Balance: 1 << 40}
+	NoError(t, c.SetStruct("profile", &profile, 30))
+	var got Profile
+	NoError(t, c.GetStruct("profile", &got))
+	Equal(t, profile, got)
+	ttl, err := c.TTL("profile")
+	NoError(t, err)
+	Equal(t, true, ttl > 0 && ttl <= 30)
+	fields, err := redis.StringMap(c.Do("HGETALL", c.getKey("profile")))
+	NoError(t, err)
+	Equal(t, 5, len(fields))
+
+	c.Del("missing")
+	Equal(t, ErrNil, c.GetStruct("missing", &got))
+
+	// 事务中的命令出错时返回该错误
+	c, err = New(Options{})
+	NoError(t, err)
+	c.pool.Dial = func() (redis.Conn, error) {
+		return &scriptConn{do: func(conn *scriptConn, commandName string, args ...interface{}) (interface{}, error) {
+			return []interface{}{int64(1), redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), int64(1)}, nil
+		}}, nil
+	}
+	err = c.SetStruct("profile", &profile, 30)
+	Equal(t, true, err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE"))
+}
+
+func TestHSetNX(t *testing.T) {
+	c := getCacher()
+	c.Del("defaults")
+	_, err := c.HSet("defaults", "theme", "dark")
+	NoError(t, err)
+
+	ok, err := c.HSetNX("defaults", "theme", "light")
+	NoError(t, err)
+	Equal(t, false, ok)
+	theme, err := c.HGetString("defaults", "theme")
+	NoError(t, err)
+	Equal(t, "dark", theme)
+
+	ok, err = c.HSetNX("defaults", "pageSize", 20)
+	NoError(t, err)
+	Equal(t, true, ok)
+	pageSize, err := c.HGetInt("defaults", "pageSize")
+	NoError(t, err)
+	Equal(t, 20, pageSize)
+}
+
+func TestHRandField(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("buckets")
+	m := map[string]interface{}{"a": 1, "b": 2, "c": 3}
+	err = c.HMSetMap("buckets", m, 10)
+	NoError(t, err)
+
+	fields, err := c.HRandField("buckets", 2)
+	NoError(t, err)
+	Equal(t, 2, len(fields))
+	for _, field := range fields {
+		_, ok := m[field]
+		Equal(t, true, ok)
+	}
+	fields, err = c.HRandField("buckets", -5)
+	NoError(t, err)
+	Equal(t, 5, len(fields))
+
+	values, err := c.HRandFieldWithValues("buckets", 3)
+	NoError(t, err)
+	Equal(t, map[string]string{"a": "1", "b": "2", "c": "3"}, values)
+}
+
+func TestHGetAll(t *testing.T) {
+	c := getCacher()
+	c.Del("huser")
+	var user User
+	Equal(t, ErrNil, c.HGetAll("huser", &user))
+
+	NoError(t, c.HMSetMap("huser", map[string]interface{}{"Name": "corel", "Age": 23}, 10))
+	NoError(t, c.HGetAll("huser", &user))
+	Equal(t, User{Name: "corel", Age: 23}, user)
+
+	// 哈希表存在，但字段值不能转换为结构体字段的类型
+	_, err := c.HSet("huser", "Age", "unknown")
+	NoError(t, err)
+	err = c.HGetAll("huser", &user)
+	Equal(t, true, err != nil && err != ErrNil)
+}
+
+func TestHMSetMap(t *testing.T) {
+	var err error
+	c := getCacher()
+	m := map[string]interface{}{
+		"name": "corel",
+		"age":  23,
+		"user": &User{Name: "corel", Age: 23},
+	}
+	err = c.HMSetMap("hmap", m, 10)
+	NoError(t, err)
+
+	name, err := c.HGetString("hmap", "name")
+	NoError(t, err)
+	Equal(t, "corel", name)
+	age, err := c.HGetInt("hmap", "age")
+	NoError(t, err)
+	Equal(t, 23, age)
+	user := &User{}
+	err = c.HGetObject("hmap", "user", user)
+	NoError(t, err)
+	Equal(t, "corel", user.Name)
+	Equal(t, 23, user.Age)
+	ttl, err := c.TTL("hmap")
+	NoError(t, err)
+	Equal(t, true, ttl > 0 && ttl <= 10)
+
+	err = c.HMSetMap("hmap", map[string]interface{}{}, 10)
+	Error(t, err)
+
+	// 键的类型错误时返回 HMSET 的错误
+	NoError(t, c.Set("hmap", "string", 10))
+	err = c.HMSetMap("hmap", m, 10)
+	Error(t, err)
+}
+
+func TestBatchWriter(t *testing.T) {
+	c := getCacher()
+	c.Del("batch:0")
+	c.Del("batch:9")
+	w := c.NewBatchWriter(4, 50*time.Millisecond)
+	for i := 0; i < 10; i++ {
+		err := w.Send("SET", c.getKey("batch:"+strconv.Itoa(i)), i)
+		NoError(t, err)
+	}
+	// 前8个命令已按数量发送，剩余的命令由定时器发送
+	exists, err := c.Exists("batch:0")
+	NoError(t, err)
+	Equal(t, true, exists)
+	time.Sleep(150 * time.Millisecond)
+	exists, err = c.Exists("batch:9")
+	NoError(t, err)
+	Equal(t, true, exists)
+
+	// 命令执行失败的错误在发送时返回，不影响其他命令
+	err = w.Send("SET", c.getKey("batch:text"), "text")
+	NoError(t, err)
+	err = w.Send("INCR", c.getKey("batch:text"))
+	NoError(t, err)
+	err = w.Close()
+	Error(t, err)
+	err = w.Send("SET", c.getKey("batch:0"), 0)
+	Equal(t, errBatchWriterClosed, err)
+}
+
+func TestSMIsMember(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("set1")
+	_, err = c.Do("SADD", c.getKey("set1"), "a", "b", 3)
+	NoError(t, err)
+	results, err := c.SMIsMember("set1", "a", "x", 3, "b", 4)
+	NoError(t, err)
+	Equal(t, []bool{true, false, true, true, false}, results)
+}
+
+func TestSMove(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("pending")
+	c.Del("done")
+	_, err = c.Do("SADD", c.getKey("pending"), "job1", 2)
+	NoError(t, err)
+
+	moved, err := c.SMove("pending", "done", 2)
+	NoError(t, err)
+	Equal(t, true, moved)
+	moved, err = c.SMove("pending", "done", 2)
+	NoError(t, err)
+	Equal(t, false, moved)
+	results, err := c.SMIsMember("done", 2, "job1")
+	NoError(t, err)
+	Equal(t, []bool{true, false}, results)
+}
+
+func TestSortedSet(t *testing.T) {
+	var err error
+	c := getCacher()
+	_, err = c.ZAdd("scores", 82, "corel")
+	NoError(t, err)
+	_, err = c.ZAdd("scores", 86, "zen")
+	NoError(t, err)
+	score, err := c.ZScore("scores", "corel")
+	NoError(t, err)
+	Equal(t, int64(82), score)
+
+	members, err := c.ZRandMember("scores", 2)
+	NoError(t, err)
+	Equal(t, 2, len(members))
+	members, err = c.ZRandMember("scores", -3)
+	NoError(t, err)
+	Equal(t, 3, len(members))
+	for _, member := range members {
+		Equal(t, true, member == "corel" || member == "zen")
+	}
+	zmembers, err := c.ZRandMemberWithScores("scores", 1)
+	NoError(t, err)
+	if len(zmembers) != 1 {
+		t.Fatalf("expected 1 member, got %d", len(zmembers))
+	}
+	expected := map[string]float64{"corel": 82, "zen": 86, "half": 90.5}
+	Equal(t, expected[zmembers[0].Member], zmembers[0].Score)
+
+	_, err = c.Do("ZADD", c.getKey("scores"), 90.5, "half")
+	NoError(t, err)
+	zmembers, err = c.ZRandMemberWithScores("scores", -6)
+	NoError(t, err)
+	Equal(t, 6, len(zmembers))
+	for _, member := range zmembers {
+		Equal(t, expected[member.Member], member.Score)
+	}
+	scores, present, err := c.ZMScore("scores", "zen", "nobody", "corel", "half")
+	NoError(t, err)
+	Equal(t, []float64{86, 0, 82, 90.5}, scores)
+	Equal(t, []bool{true, false, true, true}, present)
+}
+
+func TestZRangeByLex(t *testing.T) {
+	c := getCacher()
+	c.Del("words")
+	for _, word := range []string{"apple", "apricot", "banana", "blueberry", "cherry"} {
+		_, err := c.ZAdd("words", 0, word)
+		NoError(t, err)
+	}
+
+	words, err := c.ZRangeByLex("words", "-", "+", 0, -1)
+	NoError(t, err)
+	Equal(t, []string{"apple", "apricot", "banana", "blueberry", "cherry"}, words)
+	// 按前缀查找
+	words, err = c.ZRangeByLex("words", "[ap", "(aq", 0, -1)
+	NoError(t, err)
+	Equal(t, []string{"apple", "apricot"}, words)
+	words, err = c.ZRangeByLex("words", "(apple", "[banana", 0, -1)
+	NoError(t, err)
+	Equal(t, []string{"apricot", "banana"}, words)
+	words, err = c.ZRangeByLex("words", "-", "+", 1, 2)
+	NoError(t, err)
+	Equal(t, []string{"apricot", "banana"}, words)
+
+	words, err = c.ZRevrangeByLex("words", "+", "-", 0, 2)
+	NoError(t, err)
+	Equal(t, []string{"cherry", "blueberry"}, words)
+	words, err = c.ZRevrangeByLex("words", "(cherry", "[b", 1, -1)
+	NoError(t, err)
+	Equal(t, []string{"banana"}, words)
+}
+
+func TestZStore(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("week1")
+	c.Del("week2")
+	_, err = c.ZAdd("week1", 10, "corel")
+	NoError(t, err)
+	_, err = c.ZAdd("week1", 20, "zen")
+	NoError(t, err)
+	_, err = c.ZAdd("week2", 5, "corel")
+	NoError(t, err)
+
+	n, err := c.ZUnionStore("total", []string{"week1", "week2"}, []float64{2, 1}, "SUM")
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	score, err := c.ZScore("total", "corel")
+	NoError(t, err)
+	Equal(t, int64(25), score)
+	score, err = c.ZScore("total", "zen")
+	NoError(t, err)
+	Equal(t, int64(40), score)
+
+	n, err = c.ZInterStore("both", []string{"week1", "week2"}, []float64{1, 3}, "MAX")
+	NoError(t, err)
+	Equal(t, int64(1), n)
+	score, err = c.ZScore("both", "corel")
+	NoError(t, err)
+	Equal(t, int64(15), score)
+
+	_, err = c.ZUnionStore("total", []string{"week1", "week2"}, []float64{2}, "")
+	Error(t, err)
+}
+
+func TestZDiff(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("all")
+	c.Del("banned")
+	_, err = c.ZAdd("all", 10, "corel")
+	NoError(t, err)
+	_, err = c.ZAdd("all", 20, "zen")
+	NoError(t, err)
+	_, err = c.ZAdd("all", 30, "bob")
+	NoError(t, err)
+	_, err = c.ZAdd("banned", 1, "zen")
+	NoError(t, err)
+
+	members, err := c.ZDiff("all", "banned")
+	NoError(t, err)
+	Equal(t, []ZMember{{Member: "corel", Score: 10}, {Member: "bob", Score: 30}}, members)
+	n, err := c.ZDiffStore("allowed", "all", "banned")
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	score, err := c.ZScore("allowed", "bob")
+	NoError(t, err)
+	Equal(t, int64(30), score)
+
+	// 小数 score
+	_, err = c.Do("ZADD", c.getKey("all"), 1.5, "half")
+	NoError(t, err)
+	members, err = c.ZDiff("all", "banned")
+	NoError(t, err)
+	Equal(t, []ZMember{{Member: "half", Score: 1.5}, {Member: "corel", Score: 10}, {Member: "bob", Score: 30}}, members)
+}
+
+func TestGetSetTime(t *testing.T) {
+	var err error
+	c := getCacher()
+	now := time.Now()
+	err = c.SetTime("now", now, 30)
+	NoError(t, err)
+	val, err := c.GetTime("now")
+	NoError(t, err)
+	Equal(t, now.UnixNano(), val.UnixNano())
+
+	c.Del("now")
+	_, err = c.GetTime("now")
+	Equal(t, ErrNil, err)
+}
+
+func TestDoTimeout(t *testing.T) {
+	c, err := New(Options{Prefix: "zengate_", AllowDebug: true})
+	NoError(t, err)
+	_, err = c.DoTimeout(time.Second, "PING")
+	NoError(t, err)
+
+	start := time.Now()
+	_, err = c.DoTimeout(100*time.Millisecond, "DEBUG", "SLEEP", 0.5)
+	Equal(t, true, errors.Is(err, ErrTimeout))
+	Equal(t, true, time.Since(start) < 400*time.Millisecond)
+	time.Sleep(500 * time.Millisecond)
+}
+
+func TestMaxConnLifetime(t *testing.T) {
+	var err error
+	dials := 0
+	c := getFakeCacher(Options{MaxConnLifetime: 1}, &dials)
+
+	_, err = c.Do("PING")
+	NoError(t, err)
+	_, err = c.Do("PING")
+	NoError(t, err)
+	Equal(t, 1, dials)
+
+	time.Sleep(1100 * time.Millisecond)
+	_, err = c.Do("PING")
+	NoError(t, err)
+	Equal(t, 2, dials)
+	Equal(t, 1, c.pool.Stats().ActiveCount)
+}
+
+func TestSort(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("nums")
+	for _, n := range []string{"3", "10", "1", "2"} {
+		err = c.RPush("nums", n)
+		NoError(t, err)
+	}
+	vals, err := c.Sort("nums", SortOptions{})
+	NoError(t, err)
+	Equal(t, []string{"1", "2", "3", "10"}, vals)
+	vals, err = c.Sort("nums", SortOptions{Desc: true})
+	NoError(t, err)
+	Equal(t, []string{"10", "3", "2", "1"}, vals)
+	vals, err = c.Sort("nums", SortOptions{Alpha: true})
+	NoError(t, err)
+	Equal(t, []string{"1", "10", "2", "3"}, vals)
+	vals, err = c.Sort("nums", SortOptions{Offset: 1, Count: 2})
+	NoError(t, err)
+	Equal(t, []string{"2", "3"}, vals)
+}
+
+func TestLPos(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("letters")
+	for _, v := range []string{"a", "b", "c", "b", "b"} {
+		err = c.RPush("letters", v)
+		NoError(t, err)
+	}
+	pos, err := c.LPos("letters", "b")
+	NoError(t, err)
+	Equal(t, int64(1), pos)
+	_, err = c.LPos("letters", "z")
+	Equal(t, ErrNil, err)
+
+	positions, err := c.LPosCount("letters", "b", 0)
+	NoError(t, err)
+	Equal(t, []int64{1, 3, 4}, positions)
+	positions, err = c.LPosCount("letters", "b", 2)
+	NoError(t, err)
+	Equal(t, []int64{1, 3}, positions)
+}
+
+func TestSInterCard(t *testing.T) {
+	var err error
+	c := getCacher()
+	c.Del("set1")
+	c.Del("set2")
+	_, err = c.Do("SADD", c.getKey("set1"), "a", "b", "c", "d")
+	NoError(t, err)
+	_, err = c.Do("SADD", c.getKey("set2"), "b", "c", "d", "e")
+	NoError(t, err)
+
+	n, err := c.SInterCard(0, "set1", "set2")
+	NoError(t, err)
+	Equal(t, int64(3), n)
+	n, err = c.SInterCard(2, "set1", "set2")
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	_, err = c.SInterCard(-1, "set1", "set2")
+	Equal(t, true, err != nil)
+	_, err = c.SInterCard(0)
+	Equal(t, true, err != nil)
+}
+
+func TestZInterCard(t *testing.T) {
+	c := getCacher()
+	c.Del("zset1")
+	c.Del("zset2")
+	_, err := c.Do("ZADD", c.getKey("zset1"), 1, "a", 2, "b", 3, "c")
+	NoError(t, err)
+	_, err = c.Do("ZADD", c.getKey("zset2"), 1, "b", 2, "c", 3, "d")
+	NoError(t, err)
+
+	n, err := c.ZInterCard(0, "zset1", "zset2")
+	NoError(t, err)
+	Equal(t, int64(2), n)
+	n, err = c.ZInterCard(1, "zset1", "zset2")
+	NoError(t, err)
+	Equal(t, int64(1), n)
+	_, err = c.ZInterCard(-1, "zset1", "zset2")
+	Equal(t, true, err != nil)
+}
+
+func TestPool(t *testing.T) {
+	dials := 0
+	c := getFakeCacher(Options{}, &dials)
+	conn := c.Pool().Get()
+	_, err := conn.Do("PING")
+	NoError(t, err)
+	Equal(t, redis.PoolStats{ActiveCount: 1, IdleCount: 0}, c.Pool().Stats())
+	conn.Close()
+	Equal(t, redis.PoolStats{ActiveCount: 1, IdleCount: 1}, c.Pool().Stats())
+	Equal(t, 1, dials)
+}
+
+func TestWithConn(t *testing.T) {
+	dials := 0
+	c := getFakeCacher(Options{}, &dials)
+	err := c.WithConn(func(conn redis.Conn) error {
+		Equal(t, redis.PoolStats{ActiveCount: 1, IdleCount: 0}, c.Pool().Stats())
+		_, err := conn.Do("PING")
+		return err
+	})
+	NoError(t, err)
+	Equal(t, redis.PoolStats{ActiveCount: 1, IdleCount: 1}, c.Pool().Stats())
+
+	errFn := errors.New("failed")
+	err = c.WithConn(func(conn redis.Conn) error {
+		return errFn
+	})
+	Equal(t, errFn, err)
+	Equal(t, redis.PoolStats{ActiveCount: 1, IdleCount: 1}, c.Pool().Stats())
+	Equal(t, 1, dials)
+}
+
+func TestWait(t *testing.T) {
+	c := getCacher()
+	n, err := c.Wait(func(conn redis.Conn) error {
+		_, err := conn.Do("SET", c.getKey("wait"), "done")
+		return err
+	}, 1, 100*time.Millisecond)
+	NoError(t, err)
+	Equal(t, int64(0), n)
+	value, err := c.GetString("wait")
+	NoError(t, err)
+	Equal(t, "done", value)
+
+	errFn := errors.New("failed")
+	_, err = c.Wait(func(conn redis.Conn) error {
+		return errFn
+	}, 1, 100*time.Millisecond)
+	Equal(t, errFn, err)
+}
+
+func TestDrainIdle(t *testing.T) {
+	dials := 0
+	c := getFakeCacher(Options{}, &dials)
+	_, err := c.Do("PING")
+	NoError(t, err)
+	_, err = c.Do("PING")
+	NoError(t, err)
+	Equal(t, 1, dials)
+
+	c.DrainIdle()
+	_, err = c.Do("PING")
+	NoError(t, err)
+	Equal(t, 2, dials)
+	Equal(t, redis.PoolStats{ActiveCount: 1, IdleCount: 1}, c.Pool().Stats())
+	_, err = c.Do("PING")
+	NoError(t, err)
+	Equal(t, 2, dials)
+}
+
+func TestBorrowTestInterval(t *testing.T) {
+	newCacher := func(interval int, pings *int) *Cacher {
+		c, err := New(Options{BorrowTestInterval: interval})
+		NoError(t, err)
+		c.pool.Dial = func() (redis.Conn, error) {
+			return &scriptConn{do: func(conn *scriptConn, commandName string, args ...interface{}) (interface{}, error) {
+				if commandName == "PING" {
+					*pings++
+				}
+				return "OK", nil
+			}}, nil
+		}
+		return c
+	}
+
+	pings := 0
+	c := newCacher(0, &pings)
+	for i := 0; i < 3; i++ {
+		_, err := c.Do("GET", "name")
+		NoError(t, err)
+	}
+	// 新建立的连接不检查
+	Equal(t, 2, pings)
+
+	pings = 0
+	c = newCacher(1, &pings)
+	for i := 0; i < 3; i++ {
+		_, err := c.Do("GET", "name")
+		NoError(t, err)
+	}
+	Equal(t, 0, pings)
+	time.Sleep(1100 * time.Millisecond)
+	_, err := c.Do("GET", "name")
+	NoError(t, err)
+	Equal(t, 1, pings)
+}
+
+func TestMonitor(t *testing.T) {
+	c := getCacher()
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, err := c.Monitor(ctx)
+	NoError(t, err)
+	Equal(t, redis.PoolStats{ActiveCount: 0, IdleCount: 0}, c.Pool().Stats())
+
+	NoError(t, c.Set("monitored", "corel", 30))
+	timeout := time.After(time.Second)
+	for observed := false; !observed; {
+		select {
+		case line := <-lines:
+			// 建立连接时的 SELECT 等命令也会被输出
+			observed = strings.Contains(line, `"zengate_monitored"`)
+		case <-timeout:
+			t.Fatal("no command observed")
+		}
+	}
+
+	// 取消后通道被关闭
+	cancel()
+	for range lines {
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	// 在unix socket上监听，并将连接转发到tcp端口的redis服务
+	path := t.TempDir() + "/redis.sock"
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("unix socket not available:", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("tcp", "127.0.0.1:6379")
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			go func() {
+				io.Copy(upstream, conn)
+				upstream.Close()
+			}()
+			go func() {
+				io.Copy(conn, upstream)
+				conn.Close()
+			}()
+		}
+	}()
+
+	c, err := New(Options{Network: "unix", Addr: path, Prefix: "zengate_"})
+	NoError(t, err)
+	NoError(t, c.Set("unix", "corel", 30))
+	name, err := c.GetString("unix")
+	NoError(t, err)
+	Equal(t, "corel", name)
+	c.Shutdown(context.Background())
+}
+
+func TestShutdown(t *testing.T) {
+	dials := 0
+	c := getFakeCacher(Options{}, &dials)
+	conn := c.Pool().Get()
+	_, err := conn.Do("PING")
+	NoError(t, err)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		conn.Close()
+	}()
+	start := time.Now()
+	err = c.Shutdown(context.Background())
+	NoError(t, err)
+	Equal(t, true, time.Since(start) >= 30*time.Millisecond)
+	_, err = c.Do("GET", "name")
+	Equal(t, ErrShutdown, err)
+	_, err = c.DoTimeout(time.Second, "GET", "name")
+	Equal(t, ErrShutdown, err)
+	_, err = c.DoReadOnly("GET", "name")
+	Equal(t, ErrShutdown, err)
+
+	// 连接一直未归还时，等到 ctx 到期
+	c = getFakeCacher(Options{}, &dials)
+	conn = c.Pool().Get()
+	_, err = conn.Do("PING")
+	NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	err = c.Shutdown(ctx)
+	Equal(t, context.DeadlineExceeded, err)
+	conn.Close()
+}
+
+func TestSlowLogEntries(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{
+			int64(14), int64(1309448221), int64(15),
+			[]interface{}{[]byte("ping")},
+			[]byte("127.0.0.1:58217"), []byte("worker-123"),
+		},
+		[]interface{}{
+			int64(13), int64(1309448128), int64(30),
+			[]interface{}{[]byte("slowlog"), []byte("get"), []byte("100")},
+		},
+	}
+	entries, err := toSlowLogEntries(reply, nil)
+	NoError(t, err)
+	Equal(t, 2, len(entries))
+	Equal(t, &SlowLogEntry{
+		ID:             14,
+		Timestamp:      time.Unix(1309448221, 0),
+		DurationMicros: 15,
+		Command:        []string{"ping"},
+		ClientAddr:     "127.0.0.1:58217",
+		ClientName:     "worker-123",
+	}, entries[0])
+	Equal(t, []string{"slowlog", "get", "100"}, entries[1].Command)
+	Equal(t, "", entries[1].ClientAddr)
+}
+
+func TestCommandExists(t *testing.T) {
+	c := getCacher()
+	count, err := c.CommandCount()
+	NoError(t, err)
+	Equal(t, true, count > 0)
+	exists, err := c.CommandExists("get")
+	NoError(t, err)
+	Equal(t, true, exists)
+	exists, err = c.CommandExists("GETDEL")
+	NoError(t, err)
+	Equal(t, true, exists)
+	exists, err = c.CommandExists("nosuchcommand")
+	NoError(t, err)
+	Equal(t, false, exists)
+}
+
+func TestMemoryStats(t *testing.T) {
+	c := getCacher()
+	stats, err := c.MemoryStats()
+	NoError(t, err)
+	_, ok := stats["peak.allocated"].(int64)
+	Equal(t, true, ok)
+	advice, err := c.MemoryDoctor()
+	NoError(t, err)
+	Equal(t, true, advice != "")
+}
+
+func TestRole(t *testing.T) {
+	c := getCacher()
+	role, detail, err := c.Role()
+	NoError(t, err)
+	Equal(t, "master", role)
+	values, ok := detail.([]interface{})
+	Equal(t, true, ok)
+	Equal(t, 2, len(values))
+}
+
+func TestClientName(t *testing.T) {
+	c, err := New(Options{ClientName: "redisgo-test"})
+	NoError(t, err)
+	name, err := c.ClientGetName()
+	NoError(t, err)
+	Equal(t, "redisgo-test", name)
+}
+
+func TestClientInfos(t *testing.T) {
+	reply := "id=3 addr=127.0.0.1:51432 laddr=127.0.0.1:6379 fd=8 name=worker-1 age=12 idle=2 flags=N db=1 cmd=client|list\n" +
+		"id=5 addr=127.0.0.1:51434 laddr=127.0.0.1:6379 fd=9 name= age=3 idle=3 flags=P db=0 cmd=subscribe\n"
+	clients, err := toClientInfos(reply, nil)
+	NoError(t, err)
+	Equal(t, 2, len(clients))
+	Equal(t, int64(3), clients[0].ID)
+	Equal(t, "127.0.0.1:51432", clients[0].Addr)
+	Equal(t, "worker-1", clients[0].Name)
+	Equal(t, int64(12), clients[0].Age)
+	Equal(t, int64(2), clients[0].Idle)
+	Equal(t, "N", clients[0].Flags)
+	Equal(t, 1, clients[0].DB)
+	Equal(t, "client|list", clients[0].Cmd)
+	Equal(t, "8", clients[0].Raw["fd"])
+	Equal(t, "", clients[1].Name)
+	Equal(t, "subscribe", clients[1].Cmd)
+}
+
+func TestDebugSleep(t *testing.T) {
+	c := getCacher()
+	Equal(t, ErrDebugDisabled, c.DebugSleep(time.Second))
+
+	c, err := New(Options{Prefix: "zengate_", AllowDebug: true})
+	NoError(t, err)
+	// 在服务器阻塞前建立连接
+	conn := c.Pool().Get()
+	defer conn.Close()
+	_, err = conn.Do("PING")
+	NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.DebugSleep(time.Second)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// 服务器阻塞期间，带超时的命令应返回错误
+	_, err = redis.DoWithTimeout(conn, 200*time.Millisecond, "PING")
+	Error(t, err)
+	NoError(t, <-done)
+}
+
+func TestSubscription(t *testing.T) {
+	var buf syncBuffer
+	c, err := New(Options{Prefix: "zengate_", Logger: log.New(&buf, "", 0)})
+	NoError(t, err)
+	received := make(chan string, 10)
+	sub, err := c.Subscribe(func(channel string, data []byte) error {
+		received <- channel + ":" + string(data)
+		return nil
+	}, "zengate_ch1")
+	NoError(t, err)
+	defer sub.Close()
+
+	err = sub.Subscribe("zengate_ch2")
+	NoError(t, err)
+	Equal(t, []string{"zengate_ch1", "zengate_ch2"}, sub.Channels())
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = c.Publish("zengate_ch2", "hello")
+	NoError(t, err)
+	select {
+	case msg := <-received:
+		Equal(t, "zengate_ch2:hello", msg)
+	case <-time.After(time.Second):
+		t.Error("message on the new channel was not delivered")
+	}
+
+	err = sub.Unsubscribe("zengate_ch1")
+	NoError(t, err)
+	Equal(t, []string{"zengate_ch2"}, sub.Channels())
+
+	// 订阅和取消订阅的事件不输出日志，关闭订阅也不应输出错误
+	NoError(t, sub.Close())
+	time.Sleep(100 * time.Millisecond)
+	Equal(t, "", buf.String())
+}
+
+func TestSubscribeConfirmed(t *testing.T) {
+	c := getCacher()
+	received := make(chan string, 10)
+	sub, err := c.Subscribe(func(channel string, data []byte) error {
+		received <- channel + ":" + string(data)
+		return nil
+	}, "zengate_confirm1")
+	NoError(t, err)
+	defer sub.Close()
+	confirmed := make(chan string, 10)
+	sub.OnSubscribed(func(channel string) {
+		confirmed <- channel
+	})
+
+	// 返回时订阅已被确认，立即发布的消息也能收到
+	n, err := c.Publish("zengate_confirm1", "hello")
+	NoError(t, err)
+	Equal(t, 1, n)
+	Equal(t, "zengate_confirm1:hello", <-received)
+
+	NoError(t, sub.Subscribe("zengate_confirm2"))
+	Equal(t, "zengate_confirm2", <-confirmed)
+	n, err = c.Publish("zengate_confirm2", "world")
+	NoError(t, err)
+	Equal(t, 1, n)
+	Equal(t, "zengate_confirm2:world", <-received)
+}
+
+// stalledConn 模拟TCP连接仍然存在但不再有数据到达的连接，读取超时后才会被判定为失效
+type stalledConn struct {
+	fakeConn
+	timedOut chan struct{}
+}
+
+func (sc *stalledConn) Err() error {
+	select {
+	case <-sc.timedOut:
+		return errors.New("stalled")
+	default:
+		return nil
+	}
+}
+
+func (sc *stalledConn) Receive() (interface{}, error) {
+	<-sc.timedOut
+	return nil, errors.New("stalled")
+}
+
+func (sc *stalledConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return sc.Do(commandName, args...)
+}
+
+func (sc *stalledConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	time.Sleep(timeout)
+	close(sc.timedOut)
+	return nil, errors.New("i/o timeout")
+}
+
+func TestSubscriptionBuffer(t *testing.T) {
+	for _, test := range []struct {
+		overflow string
+		expected []string
+	}{
+		{"drop-newest", []string{"m1", "m2"}},
+		{"drop-oldest", []string{"m1", "m3"}},
+	} {
+		dials := 0
+		c := getFakeCacher(Options{SubscribeBuffer: 1, SubscribeOverflow: test.overflow}, &dials)
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+		handled := make(chan string, 10)
+		s := newSubscription(c, func(channel string, data []byte) error {
+			started <- struct{}{}
+			<-release
+			handled <- string(data)
+			return nil
+		}, "ch")
+
+		// m1 正在处理，m2 在缓冲区中，缓冲区已满
+		s.deliver(redis.Message{Channel: "ch", Data: []byte("m1")})
+		<-started
+		s.deliver(redis.Message{Channel: "ch", Data: []byte("m2")})
+		s.deliver(redis.Message{Channel: "ch", Data: []byte("m3")})
+		Equal(t, uint64(1), s.Dropped())
+
+		close(s.messages)
+		close(release)
+		received := []string{<-handled, <-handled}
+		Equal(t, test.expected, received)
+	}
+}
+
+func TestSubscriptionHeartbeat(t *testing.T) {
+	c, err := New(Options{SubscribeHeartbeat: 1})
+	NoError(t, err)
+	c.heartbeat = 50 * time.Millisecond
+	dials := make(chan struct{}, 10)
+	c.pool.Dial = func() (redis.Conn, error) {
+		dials <- struct{}{}
+		return &stalledConn{timedOut: make(chan struct{})}, nil
+	}
+	sub, err := c.Subscribe(func(channel string, data []byte) error {
+		return nil
+	}, "ch")
+	NoError(t, err)
+	defer sub.Close()
+	Equal(t, 50*time.Millisecond, sub.HeartbeatInterval())
+
+	<-dials
+	select {
+	case <-dials:
+	case <-time.After(3 * time.Second):
+		t.Error("stalled subscription was not reconnected")
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var err error
+	dials := 0
+	c := getFakeCacher(Options{BreakerThreshold: 3}, &dials)
+	c.breaker.cooldown = 50 * time.Millisecond
+	down := true
+	c.pool.Dial = func() (redis.Conn, error) {
+		dials++
+		if down {
+			return nil, errors.New("connection refused")
+		}
+		return &fakeConn{}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = c.Do("GET", "name")
+		Error(t, err)
+	}
+	Equal(t, 3, dials)
+	// 熔断器打开后不再建立连接
+	_, err = c.Do("GET", "name")
+	Equal(t, ErrCircuitOpen, err)
+	Equal(t, 3, dials)
+
+	// 冷却后只允许一个探测命令，失败则再次打开
+	time.Sleep(60 * time.Millisecond)
+	_, err = c.Do("GET", "name")
+	Error(t, err)
+	Equal(t, 4, dials)
+	_, err = c.Do("GET", "name")
+	Equal(t, ErrCircuitOpen, err)
+
+	// 探测成功后关闭
+	down = false
+	time.Sleep(60 * time.Millisecond)
+	_, err = c.Do("GET", "name")
+	NoError(t, err)
+	_, err = c.Do("GET", "name")
+	NoError(t, err)
+}
+
+func TestDangerousCommands(t *testing.T) {
+	var err error
+	dials := 0
+	c := getFakeCacher(Options{}, &dials)
+	err = c.FlushAll(false)
+	Equal(t, ErrDangerousCommandDisabled, err)
+	err = c.Flush()
+	Equal(t, ErrDangerousCommandDisabled, err)
+	_, err = c.Do("keys", "*")
+	Equal(t, ErrDangerousCommandDisabled, err)
+	_, err = c.DoTimeout(time.Second, "FLUSHALL")
+	Equal(t, ErrDangerousCommandDisabled, err)
+	_, err = c.DoReadOnly("FLUSHDB")
+	Equal(t, ErrDangerousCommandDisabled, err)
+	_, err = c.ReadOnly().Do("KEYS", "*")
+	Equal(t, ErrDangerousCommandDisabled, err)
+	p := c.NewPipeline()
+	p.Send("GET", "name")
+	p.Send("FLUSHALL")
+	_, err = p.Exec()
+	Equal(t, ErrDangerousCommandDisabled, err)
+	Equal(t, 0, dials)
+	w := c.NewBatchWriter(10, 0)
+	Equal(t, ErrDangerousCommandDisabled, w.Send("FLUSHDB"))
+	NoError(t, w.Send("SET", "name", "corel"))
+	NoError(t, w.Close())
+	_, err = c.Do("GET", "name")
+	NoError(t, err)
+
+	c = getFakeCacher(Options{AllowDangerousCommands: true}, &dials)
+	err = c.FlushAll(true)
+	NoError(t, err)
+	err = c.Flush()
+	NoError(t, err)
+}
+
+func TestFlush(t *testing.T) {
+	c, err := New(Options{Prefix: "zengate_", AllowDangerousCommands: true})
+	NoError(t, err)
+	NoError(t, c.Set("flushed", "v", 30))
+	NoError(t, c.Flush())
+	ok, err := c.Exists("flushed")
+	NoError(t, err)
+	Equal(t, false, ok)
+}
+
+func TestDoReadOnly(t *testing.T) {
+	var err error
+	masterDials, replicaDials := 0, 0
+	c := getFakeCacher(Options{ReplicaAddrs: []string{"127.0.0.1:6380", "127.0.0.1:6381"}}, &masterDials)
+	for _, pool := range c.replicas.pools {
+		pool.Dial = func() (redis.Conn, error) {
+			replicaDials++
+			return &fakeConn{}, nil
+		}
+	}
+
+	_, err = c.DoReadOnly("GET", "name")
+	NoError(t, err)
+	_, err = c.DoReadOnly("GET", "name")
+	NoError(t, err)
+	Equal(t, 0, masterDials)
+	Equal(t, 2, replicaDials)
+
+	_, err = c.Do("SET", "name", "corel")
+	NoError(t, err)
+	Equal(t, 1, masterDials)
+	Equal(t, 2, replicaDials)
+}
+
+func TestReadOnly(t *testing.T) {
+	var err error
+	masterDials, replicaDials := 0, 0
+	c := getFakeCacher(Options{ReplicaAddrs: []string{"127.0.0.1:6380"}}, &masterDials)
+	c.replicas.pools[0].Dial = func() (redis.Conn, error) {
+		replicaDials++
+		return &fakeConn{}, nil
+	}
+
+	_, err = c.ReadOnly().GetString("name")
+	NoError(t, err)
+	Equal(t, 0, masterDials)
+	Equal(t, 1, replicaDials)
+
+	err = c.Set("name", "corel", 0)
+	NoError(t, err)
+	Equal(t, 1, masterDials)
+	Equal(t, 1, replicaDials)
+}
+
+func TestKeySlot(t *testing.T) {
+	Equal(t, uint16(0x31C3), crc16([]byte("123456789")))
+	Equal(t, 12182, keySlot("foo"))
+	Equal(t, 5061, keySlot("bar"))
+	Equal(t, keySlot("user1000"), keySlot("{user1000}.following"))
+	Equal(t, keySlot("user1000"), keySlot("{user1000}.followers"))
+	Equal(t, "{user:42}:profile", HashTag("user:42", ":profile"))
+	Equal(t, keySlot("zengate_"+HashTag("user:42", ":profile")), keySlot("zengate_"+HashTag("user:42", ":posts")))
+	// {} 中没有内容时使用整个键名
+	Equal(t, int(crc16([]byte("foo{}{bar}"))%clusterSlots), keySlot("foo{}{bar}"))
+}
+
+func TestSentinelMasterAddr(t *testing.T) {
+	var conns []*scriptConn
+	dial := func(addr string) (redis.Conn, error) {
+		if addr == "down" {
+			return nil, errors.New("connection refused")
+		}
+		conn := &scriptConn{do: func(conn *scriptConn, commandName string, args ...interface{}) (interface{}, error) {
+			if commandName == "AUTH" {
+				if args[0] != "secret" {
+					return nil, redis.Error("WRONGPASS invalid password")
+				}
+				return "OK", nil
+			}
+			if args[1] != "mymaster" {
+				return nil, nil
+			}
+			return []interface{}{[]byte("10.0.0.1"), []byte("6379")}, nil
+		}}
+		conns = append(conns, conn)
+		return conn, nil
+	}
+
+	// 跳过无法连接的哨兵
+	addr, err := sentinelMasterAddr(dial, []string{"down", "up"}, "mymaster", "")
+	NoError(t, err)
+	Equal(t, "10.0.0.1:6379", addr)
+	Equal(t, 1, len(conns))
+	Equal(t, true, conns[0].closed)
+
+	addr, err = sentinelMasterAddr(dial, []string{"up"}, "mymaster", "secret")
+	NoError(t, err)
+	Equal(t, "10.0.0.1:6379", addr)
+
+	_, err = sentinelMasterAddr(dial, []string{"up"}, "mymaster", "wrong")
+	Equal(t, true, err != nil && strings.Contains(err.Error(), "WRONGPASS"))
+
+	_, err = sentinelMasterAddr(dial, []string{"down", "up"}, "unknown", "")
+	Error(t, err)
+}
+
+func TestClusterRedirect(t *testing.T) {
+	cl := &cluster{seeds: []string{"node1"}, pools: make(map[string]*redis.Pool)}
+	addNode := func(addr string, do func(conn *scriptConn, commandName string, args ...interface{}) (interface{}, error)) {
+		cl.pools[addr] = &redis.Pool{Dial: func() (redis.Conn, error) {
+			return &scriptConn{do: do}, nil
+		}}
+	}
+	addNode("node1", func(conn *scriptConn, commandName string, args ...interface{}) (interface{}, error) {
+		if args[0] == "foo" {
+			return nil, redis.Error("MOVED 12182 node2")
+		}
+		return nil, redis.Error("ASK 5061 node3")
+	})
+	addNode("node2", func(conn *scriptConn, commandName string, args ...interface{}) (interface{}, error) {
+		return []byte("foo-value"), nil
+	})
+	addNode("node3", func(conn *scriptConn, commandName string, args ...interface{}) (interface{}, error) {
+		if len(conn.sent) == 0 || conn.sent[0] != "ASKING" {
+			return nil, redis.Error("MOVED 5061 node1")
+		}
+		return []byte("bar-value"), nil
+	})
+
+	val, err := String(cl.do("GET", "foo"))
+	NoError(t, err)
+	Equal(t, "foo-value", val)
+	Equal(t, "node2", cl.slots[12182])
+
+	// ASK 只对当前命令有效，不更新哈希槽的映射
+	val, err = String(cl.do("GET", "bar"))
+	NoError(t, err)
+	Equal(t, "bar-value", val)
+	Equal(t, "", cl.slots[5061])
+}
+
+func TestWarmup(t *testing.T) {
+	dials := 0
+	c := getFakeCacher(Options{MaxIdle: 3}, &dials)
+	err := c.Warmup(5)
+	NoError(t, err)
+	Equal(t, 3, dials)
+	Equal(t, redis.PoolStats{ActiveCount: 3, IdleCount: 3}, c.Pool().Stats())
+
+	// 已预热的连接直接从连接池中取出
+	_, err = c.Do("PING")
+	NoError(t, err)
+	Equal(t, 3, dials)
+}
+
+// syncBuffer 可以被多个协程同时写入的 bytes.Buffer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLeakDetection(t *testing.T) {
+	var buf syncBuffer
+	dials := 0
+	c := getFakeCacher(Options{LeakThreshold: 1, Logger: log.New(&buf, "", 0)}, &dials)
+
+	conn := c.getConn("name")
+	conn.Close()
+	leaked := c.getConn("name")
+	time.Sleep(1100 * time.Millisecond)
+	leaked.Close()
+
+	Equal(t, 1, strings.Count(buf.String(), "possible leak"))
+	Equal(t, true, strings.Contains(buf.String(), "TestLeakDetection"))
+}
+
+func TestScript(t *testing.T) {
+	var err error
+	c := getCacher()
+	err = c.Set("name", "corel", 30)
+	NoError(t, err)
+	script := c.NewScript(1, "return redis.call('GET', KEYS[1]) .. ARGV[1]")
+	val, err := String(script.Run([]string{"name"}, "!"))
+	NoError(t, err)
+	Equal(t, "corel!", val)
+
+	// 脚本缓存被清空后自动重新加载
+	_, err = c.Do("SCRIPT", "FLUSH")
+	NoError(t, err)
+	val, err = String(script.Run([]string{"name"}, "?"))
+	NoError(t, err)
+	Equal(t, "corel?", val)
+	exists, err := redis.Ints(c.Do("SCRIPT", "EXISTS", script.Hash()))
+	NoError(t, err)
+	Equal(t, []int{1}, exists)
+
+	_, err = script.Run(nil)
+	Error(t, err)
+}
+
+func TestErrorClassification(t *testing.T) {
+	_, dialErr := net.Dial("tcp", "127.0.0.1:1")
+	timeoutErr := fmt.Errorf("%w: GET after 1s", ErrTimeout)
+	tests := []struct {
+		err                     error
+		isNil, timeout, connErr bool
+	}{
+		{nil, false, false, false},
+		{ErrNil, true, false, false},
+		{fmt.Errorf("wrapped: %w", redis.ErrNil), true, false, false},
+		{timeoutErr, false, true, false},
+		{dialErr, false, false, true},
+		{io.EOF, false, false, true},
+		{redis.ErrPoolExhausted, false, false, true},
+		{redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), false, false, false},
+	}
+	for _, test := range tests {
+		Equal(t, test.isNil, IsNil(test.err))
+		Equal(t, test.timeout, IsTimeout(test.err))
+		Equal(t, test.connErr, IsConnError(test.err))
+	}
+}
+
+func TestRedisError(t *testing.T) {
+	var err error
+	c := getCacher()
+	err = c.Set("name", "corel", 30)
+	NoError(t, err)
+	_, err = c.HGet("name", "age")
+	Equal(t, true, IsWrongType(err))
+	Equal(t, false, IsOOM(err))
+	var redisErr *RedisError
+	Equal(t, true, errors.As(err, &redisErr))
+	Equal(t, "WRONGTYPE", redisErr.Code())
+
+	redisErr = &RedisError{err: redis.Error("OOM command not allowed when used memory > 'maxmemory'.")}
+	Equal(t, "OOM", redisErr.Code())
+	Equal(t, "command not allowed when used memory > 'maxmemory'.", redisErr.Message())
+	Equal(t, true, IsOOM(redisErr))
+	Equal(t, true, IsOOM(redis.Error(redisErr.Error())))
+}
+
+func TestSetIfGreater(t *testing.T) {
+	c := getCacher()
+	c.Del("maxseq")
+	for _, test := range []struct {
+		value    int64
+		updated  bool
+		expected int64
+	}{
+		{5, true, 5},
+		{3, false, 5},
+		{5, false, 5},
+		{8, true, 8},
+	} {
+		updated, err := c.SetIfGreater("maxseq", test.value)
+		NoError(t, err)
+		Equal(t, test.updated, updated)
+		val, err := c.GetInt64("maxseq")
+		NoError(t, err)
+		Equal(t, test.expected, val)
+	}
+}
+
+func TestListEdit(t *testing.T) {
+	c := getCacher()
+	c.Del("list")
+	NoError(t, c.RPush("list", "a"))
+	NoError(t, c.RPush("list", "c"))
+
+	n, err := c.LInsert("list", true, "c", "b")
+	NoError(t, err)
+	Equal(t, int64(3), n)
+	n, err = c.LInsert("list", false, "c", 4)
+	NoError(t, err)
+	Equal(t, int64(4), n)
+	n, err = c.LInsert("list", false, "x", "y")
+	NoError(t, err)
+	Equal(t, int64(-1), n)
+
+	err = c.LSet("list", -1, "d")
+	NoError(t, err)
+	err = c.LSet("list", 10, "e")
+	Error(t, err)
+
+	val, err := c.LIndex("list", 1)
+	NoError(t, err)
+	Equal(t, "b", val)
+	val, err = c.LIndex("list", -1)
+	NoError(t, err)
+	Equal(t, "d", val)
+	_, err = c.LIndex("list", 10)
+	Equal(t, ErrNil, err)
+}
+
+func TestListMove(t *testing.T) {
+	c := getCacher()
+	c.Del("src")
+	c.Del("dst")
+	NoError(t, c.RPush("src", "a"))
+	NoError(t, c.RPush("src", "b"))
+	NoError(t, c.RPush("src", "c"))
+
+	val, err := c.RPopLPush("src", "dst")
+	NoError(t, err)
+	Equal(t, "c", val)
+	val, err = c.LMove("src", "dst", "LEFT", "RIGHT")
+	NoError(t, err)
+	Equal(t, "a", val)
+	values, err := redis.Strings(c.LRange("dst", 0, -1))
+	NoError(t, err)
+	Equal(t, []string{"c", "a"}, values)
+
+	// 轮转列表
+	val, err = c.RPopLPush("dst", "dst")
+	NoError(t, err)
+	Equal(t, "a", val)
+	values, err = redis.Strings(c.LRange("dst", 0, -1))
+	NoError(t, err)
+	Equal(t, []string{"a", "c"}, values)
+
+	c.Del("src")
+	_, err = c.RPopLPush("src", "dst")
+	Equal(t, ErrNil, err)
+	_, err = c.LMove("src", "dst", "LEFT", "LEFT")
+	Equal(t, ErrNil, err)
+}
+
+func TestReliableQueue(t *testing.T) {
+	c := getCacher()
+	c.Del("jobs")
+	c.Del("jobs:processing")
+	NoError(t, c.Enqueue("jobs", "job1"))
+	NoError(t, c.Enqueue("jobs", "job2"))
+
+	item, err := c.Dequeue("jobs", "jobs:processing")
+	NoError(t, err)
+	Equal(t, "job1", item)
+	NoError(t, c.Ack("jobs:processing", item))
+
+	// 未确认的任务保留在处理中列表，可以放回队列
+	item, err = c.Dequeue("jobs", "jobs:processing")
+	NoError(t, err)
+	Equal(t, "job2", item)
+	_, err = c.Dequeue("jobs", "jobs:processing")
+	Equal(t, ErrNil, err)
+	count, err := c.Requeue("jobs:processing", "jobs")
+	NoError(t, err)
+	Equal(t, 1, count)
+	item, err = c.Dequeue("jobs", "jobs:processing")
+	NoError(t, err)
+	Equal(t, "job2", item)
+}
+
+func TestIncrCapped(t *testing.T) {
+	c := getCacher()
+	c.Del("quota")
+	for _, test := range []struct {
+		value  int64
+		capped bool
+	}{
+		{4, false},
+		{8, false},
+		{10, true},
+		{10, true},
+	} {
+		val, capped, err := c.IncrCapped("quota", 4, 10, 60)
+		NoError(t, err)
+		Equal(t, test.value, val)
+		Equal(t, test.capped, capped)
+	}
+	ttl, err := c.TTL("quota")
+	NoError(t, err)
+	Equal(t, true, ttl > 0 && ttl <= 60)
+}
+
+func TestDelayQueueJobs(t *testing.T) {
+	c := getCacher()
+	c.Del("jobs")
+	q := c.NewDelayQueue("jobs")
+	now := time.Now()
+	id1, err := q.ScheduleJob(&User{Name: "corel"}, now.Add(-2*time.Second))
+	NoError(t, err)
+	id2, err := q.ScheduleJob(&User{Name: "corel"}, now.Add(-time.Second))
+	NoError(t, err)
+	Equal(t, true, id1 != id2)
+	_, err = q.ScheduleJob("later", now.Add(time.Minute))
+	NoError(t, err)
+
+	jobs, err := q.PollDueJobs(now, 10)
+	NoError(t, err)
+	Equal(t, 2, len(jobs))
+	if len(jobs) == 2 {
+		Equal(t, id1, jobs[0].ID)
+		Equal(t, id2, jobs[1].ID)
+		var user User
+		NoError(t, c.Decode(jobs[0].Payload, &user))
+		Equal(t, "corel", user.Name)
+	}
+	jobs, err = q.PollDueJobs(now, 10)
+	NoError(t, err)
+	Equal(t, 0, len(jobs))
+
+	// 通过 Schedule 添加的任务不会丢失
+	NoError(t, q.Schedule("plain", now.Add(-time.Second)))
+	jobs, err = q.PollDueJobs(now, 10)
+	NoError(t, err)
+	Equal(t, []Job{{Payload: "plain"}}, jobs)
+}
+
+func TestParseJob(t *testing.T) {
+	id := strings.Repeat("ab", 16)
+	Equal(t, Job{ID: id, Payload: `{"a":1}`}, parseJob(id+`:{"a":1}`))
+	Equal(t, Job{Payload: "plain"}, parseJob("plain"))
+	Equal(t, Job{Payload: `{"a":1}`}, parseJob(`{"a":1}`))
+	Equal(t, Job{Payload: strings.Repeat("x", 32) + ":y"}, parseJob(strings.Repeat("x", 32)+":y"))
+}
+
+func TestPromoteKey(t *testing.T) {
+	c := getCacher()
+	c.Del("live")
+	err := c.Set("staging", "v2", 0)
+	NoError(t, err)
+	err = c.PromoteKey("staging", "live", 60)
+	NoError(t, err)
+
+	val, err := c.GetString("live")
+	NoError(t, err)
+	Equal(t, "v2", val)
+	ttl, err := c.TTL("live")
+	NoError(t, err)
+	Equal(t, true, ttl > 0 && ttl <= 60)
+	exists, err := c.Exists("staging")
+	NoError(t, err)
+	Equal(t, false, exists)
+
+	// staging 不存在时返回错误
+	err = c.PromoteKey("staging", "live", 60)
+	Error(t, err)
+}
+
+func TestPipeline(t *testing.T) {
+	c := getCacher()
+	c.Del("list")
+	NoError(t, c.LPush("list", "a"))
+	p := c.NewPipeline()
+	p.Send("SET", "zengate_name", "corel")
+	p.Send("INCR", "zengate_list")
+	p.Send("GET", "zengate_name")
+	Equal(t, 3, p.Len())
+	results, err := p.Exec()
+	NoError(t, err)
+	Equal(t, 3, len(results))
+	NoError(t, results[0].Err)
+	Equal(t, true, results[1].Err != nil && strings.Contains(results[1].Err.Error(), "WRONGTYPE"))
+	NoError(t, results[2].Err)
+	name, err := String(results[2].Value, nil)
+	NoError(t, err)
+	Equal(t, "corel", name)
+	Equal(t, 0, p.Len())
+
+	results, err = p.Exec()
+	NoError(t, err)
+	Equal(t, 0, len(results))
+}
+
+func TestPushAndReturnTrimmed(t *testing.T) {
+	c := getCacher()
+	c.Del("audit")
+	trimmed, err := c.PushAndReturnTrimmed("audit", 3, "e1", "e2")
+	NoError(t, err)
+	Equal(t, 0, len(trimmed))
+	trimmed, err = c.PushAndReturnTrimmed("audit", 3, "e3", "e4", "e5")
+	NoError(t, err)
+	Equal(t, []string{"e2", "e1"}, trimmed)
+	values, err := redis.Strings(c.LRange("audit", 0, -1))
+	NoError(t, err)
+	Equal(t, []string{"e5", "e4", "e3"}, values)
+}
+
+func TestObjectFreq(t *testing.T) {
+	c := getCacher()
+	err := c.Set("hot", "value", 30)
+	NoError(t, err)
+	before, err := c.ObjectFreq("hot")
+	if err == ErrLFUDisabled {
+		t.Skip("maxmemory-policy is not an LFU policy")
+	}
+	NoError(t, err)
+	for i := 0; i < 100; i++ {
+		c.Get("hot")
+	}
+	after, err := c.ObjectFreq("hot")
+	NoError(t, err)
+	Equal(t, true, after > before)
+}
+
+func TestMemoryUsage(t *testing.T) {
+	c := getCacher()
+	NoError(t, c.Set("sized", "value", 30))
+	n, err := c.MemoryUsage("sized")
+	NoError(t, err)
+	Equal(t, true, n > 0)
+
+	c.Del("missing")
+	_, err = c.MemoryUsage("missing")
+	Equal(t, ErrNil, err)
+}
+
+func TestTime(t *testing.T) {
+	c := getCacher()
+	now, err := c.Time()
+	NoError(t, err)
+	diff := time.Since(now)
+	Equal(t, true, diff > -5*time.Second && diff < 5*time.Second)
+	Equal(t, true, now.Nanosecond()%int(time.Microsecond) == 0)
+
+	saved, err := c.LastSave()
+	NoError(t, err)
+	Equal(t, false, saved.IsZero())
+	Equal(t, true, saved.Unix() > 0 && !saved.After(now.Add(time.Second)))
+}
+
+func TestSemaphore(t *testing.T) {
+	c := getCacher()
+	c.Del("sem")
+	sem := c.NewSemaphore("sem", 2, 1)
+	token1, ok, err := sem.Acquire()
+	NoError(t, err)
+	Equal(t, true, ok)
+	_, ok, err = sem.Acquire()
+	NoError(t, err)
+	Equal(t, true, ok)
+	_, ok, err = sem.Acquire()
+	NoError(t, err)
+	Equal(t, false, ok)
+
+	err = sem.Release(token1)
+	NoError(t, err)
+	_, ok, err = sem.Acquire()
+	NoError(t, err)
+	Equal(t, true, ok)
+
+	// 过期的令牌被自动清除
+	time.Sleep(1100 * time.Millisecond)
+	_, ok, err = sem.Acquire()
+	NoError(t, err)
+	Equal(t, true, ok)
+}
+
+func TestGetOrLoadRefresh(t *testing.T) {
+	c := getCacher()
+	c.Del("refresh")
+	var loads int32
+	load := func() (interface{}, error) {
+		n := atomic.AddInt32(&loads, 1)
+		time.Sleep(50 * time.Millisecond)
+		return map[string]int32{"version": n}, nil
+	}
+
+	// 并发的首次加载只执行一次
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var v map[string]int32
+			NoError(t, c.GetOrLoadRefresh("refresh", &v, 10, 0.5, load))
+			Equal(t, int32(1), v["version"])
+		}()
+	}
+	wg.Wait()
+	Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// 剩余时间充足时不重新加载
+	var v map[string]int32
+	NoError(t, c.GetOrLoadRefresh("refresh", &v, 10, 0.5, load))
+	Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// 剩余时间不足时返回当前值，并在后台重新加载
+	NoError(t, c.Expire("refresh", 2))
+	NoError(t, c.GetOrLoadRefresh("refresh", &v, 10, 0.5, load))
+	Equal(t, int32(1), v["version"])
+	time.Sleep(200 * time.Millisecond)
+	Equal(t, int32(2), atomic.LoadInt32(&loads))
+	NoError(t, c.GetOrLoadRefresh("refresh", &v, 10, 0.5, load))
+	Equal(t, int32(2), v["version"])
+}
+
+func TestGetTracked(t *testing.T) {
+	c, err := New(Options{Prefix: "zengate_", TrackingCacheSize: 10})
+	NoError(t, err)
+	defer c.Shutdown(context.Background())
+	other := getCacher()
+	NoError(t, other.Set("tracked", "v1", 30))
+	// 等待订阅失效通知的连接建立
+	for i := 0; i < 100; i++ {
+		c.tracker.mu.Lock()
+		id := c.tracker.id
+		c.tracker.mu.Unlock()
+		if id != 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var value string
+	NoError(t, c.GetTracked("tracked", &value))
+	Equal(t, "v1", value)
+	cached, ok := c.tracker.cache.get("zengate_tracked")
+	Equal(t, true, ok)
+	Equal(t, "v1", cached)
+	NoError(t, c.GetTracked("tracked", &value))
+	Equal(t, "v1", value)
+
+	// 其他客户端修改后缓存失效
+	NoError(t, other.Set("tracked", "v2", 30))
+	for i := 0; i < 100; i++ {
+		if _, ok = c.tracker.cache.get("zengate_tracked"); !ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	Equal(t, false, ok)
+	NoError(t, c.GetTracked("tracked", &value))
+	Equal(t, "v2", value)
+
+	c.Del("missing")
+	Equal(t, ErrNil, c.GetTracked("missing", &value))
+
+	// 读取使用开启了跟踪的专用连接，不会放回连接池
+	Equal(t, true, c.tracker.conn != nil)
+	NoError(t, c.Shutdown(context.Background()))
+	Equal(t, true, c.tracker.conn == nil)
+
+	_, err = New(Options{ClusterAddrs: []string{"127.0.0.1:7000"}, TrackingCacheSize: 10})
+	Error(t, err)
+}
+
+func TestVersioned(t *testing.T) {
+	c := getCacher()
+	c.Del("feed")
+	c.Del("feed:version")
+	var user User
+	_, err := c.GetVersioned("feed", "feed:version", &user)
+	Equal(t, ErrNil, err)
+
+	NoError(t, c.SetVersioned("feed", "feed:version", &User{Name: "corel"}, 30))
+	stale, err := c.GetVersioned("feed", "feed:version", &user)
+	NoError(t, err)
+	Equal(t, false, stale)
+	Equal(t, "corel", user.Name)
+
+	version, err := c.BumpVersion("feed:version")
+	NoError(t, err)
+	Equal(t, int64(1), version)
+	stale, err = c.GetVersioned("feed", "feed:version", &user)
+	NoError(t, err)
+	Equal(t, true, stale)
+
+	NoError(t, c.SetVersioned("feed", "feed:version", 23, 30))
+	var age int
+	stale, err = c.GetVersioned("feed", "feed:version", &age)
+	NoError(t, err)
+	Equal(t, false, stale)
+	Equal(t, 23, age)
+
+	NoError(t, c.Set("feed", "plain", 30))
+	_, err = c.GetVersioned("feed", "feed:version", &user)
+	Error(t, err)
+}
+
+func TestLockWait(t *testing.T) {
+	c := getCacher()
+	c.Del("lock")
+	token, ok, err := c.TryLock("lock", 10)
+	NoError(t, err)
+	Equal(t, true, ok)
+	_, ok, err = c.TryLock("lock", 10)
+	NoError(t, err)
+	Equal(t, false, ok)
+
+	_, err = c.LockWait(context.Background(), "lock", 10, 0)
+	Error(t, err)
+
+	// 锁被占用时 ctx 超时返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.LockWait(ctx, "lock", 10, 10*time.Millisecond)
+	Equal(t, context.DeadlineExceeded, err)
+
+	acquired := make(chan string)
+	go func() {
+		token, err := c.LockWait(context.Background(), "lock", 10, 10*time.Millisecond)
+		NoError(t, err)
+		acquired <- token
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("lock acquired while held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	NoError(t, c.Unlock("lock", token))
+	select {
+	case token2 := <-acquired:
+		Equal(t, true, token != token2)
+		NoError(t, c.Unlock("lock", token2))
+	case <-time.After(time.Second):
+		t.Fatal("lock not acquired after unlock")
+	}
+}
+
+func TestDelayQueue(t *testing.T) {
+	c := getCacher()
+	c.Del("delay")
+	q := c.NewDelayQueue("delay")
+	now := time.Now()
+	NoError(t, q.Schedule("job2", now.Add(-time.Second)))
+	NoError(t, q.Schedule("job1", now.Add(-2*time.Second)))
+	NoError(t, q.Schedule("job3", now.Add(time.Minute)))
+
+	jobs, err := q.Poll(now, 10)
+	NoError(t, err)
+	Equal(t, []string{"job1", "job2"}, jobs)
+	jobs, err = q.Poll(now, 10)
+	NoError(t, err)
+	Equal(t, 0, len(jobs))
+	jobs, err = q.Poll(now.Add(2*time.Minute), 10)
+	NoError(t, err)
+	Equal(t, []string{"job3"}, jobs)
 }
@@ -0,0 +1,77 @@
+package redisgo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Semaphore 基于有序集合实现的分布式信号量，最多允许 limit 个持有者同时持有。
+// 每个持有者以随机令牌的形式保存在有序集合中，score 为获取的时间，超过有效时长未释放的令牌会被自动清除，避免持有者崩溃后信号量无法释放。
+// 获取的时间使用调用方的本地时间，所以各实例的时钟应保持同步。
+type Semaphore struct {
+	c      *Cacher
+	key    string
+	limit  int
+	expire int64
+}
+
+// NewSemaphore 创建分布式信号量，expire 为令牌的有效时长，单位为秒。
+// Example:
+//
+// ```golang
+// sem := c.NewSemaphore("jobs", 10, 60)
+// token, ok, err := sem.Acquire()
+// // 获取成功后执行任务，完成后释放
+// err = sem.Release(token)
+// ```
+func (c *Cacher) NewSemaphore(key string, limit int, expire int64) *Semaphore {
+	return &Semaphore{
+		c:      c,
+		key:    key,
+		limit:  limit,
+		expire: expire,
+	}
+}
+
+var semaphoreAcquireScript = redis.NewScript(-1, `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', tonumber(ARGV[1]) - tonumber(ARGV[2]))
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// Acquire 尝试获取信号量，不会阻塞。获取成功时 ok 为 true ，并返回用于释放的令牌。
+func (s *Semaphore) Acquire() (token string, ok bool, err error) {
+	token, err = randomToken()
+	if err != nil {
+		return "", false, err
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	expire := s.expire * 1000
+	ok, err = Bool(s.c.evalScript(semaphoreAcquireScript, []string{s.key}, now, expire, s.limit, token))
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return token, true, nil
+}
+
+// Release 释放通过 Acquire 获取的令牌。令牌已过期时不做任何操作。
+func (s *Semaphore) Release(token string) error {
+	_, err := s.c.Do("ZREM", s.c.getKey(s.key), token)
+	return err
+}
+
+// randomToken 返回随机的令牌
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,56 @@
+package redisgo
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// sentinelTimeout 连接哨兵及查询主节点地址的超时时间
+const sentinelTimeout = time.Second
+
+// dialSentinel 返回连接哨兵的方法
+func dialSentinel(network string) func(addr string) (redis.Conn, error) {
+	return func(addr string) (redis.Conn, error) {
+		return redis.Dial(network, addr,
+			redis.DialConnectTimeout(sentinelTimeout),
+			redis.DialReadTimeout(sentinelTimeout),
+			redis.DialWriteTimeout(sentinelTimeout))
+	}
+}
+
+// sentinelMasterAddr 依次询问哨兵，返回主节点 masterName 当前的地址。password 不为空时先通过 AUTH 认证
+func sentinelMasterAddr(dial func(addr string) (redis.Conn, error), sentinelAddrs []string, masterName, password string) (string, error) {
+	var lastErr error
+	for _, sentinelAddr := range sentinelAddrs {
+		addr, err := querySentinel(dial, sentinelAddr, masterName, password)
+		if err == nil {
+			return addr, nil
+		}
+		lastErr = err
+	}
+	return "", fmt.Errorf("redisgo: no sentinel could resolve master %s: %v", masterName, lastErr)
+}
+
+func querySentinel(dial func(addr string) (redis.Conn, error), sentinelAddr, masterName, password string) (string, error) {
+	conn, err := dial(sentinelAddr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if password != "" {
+		if _, err := conn.Do("AUTH", password); err != nil {
+			return "", err
+		}
+	}
+	values, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", masterName))
+	if err != nil {
+		return "", err
+	}
+	if len(values) != 2 {
+		return "", fmt.Errorf("redisgo: unexpected number of values, got %d", len(values))
+	}
+	return net.JoinHostPort(values[0], values[1]), nil
+}
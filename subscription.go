@@ -0,0 +1,324 @@
+package redisgo
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var errSubscriptionClosed = errors.New("redisgo: subscription closed")
+
+// Subscription 由 Subscribe 返回的订阅，可以在订阅过程中动态地增加或取消订阅的频道。
+// 连接异常断开后会自动重新连接，并重新订阅当前所有的频道。
+type Subscription struct {
+	dropped uint64 // 缓冲区已满时丢弃的消息数量，原子地读写。放在第一个字段以保证在32位平台上64位对齐
+
+	c         *Cacher
+	onMessage func(channel string, data []byte) error
+	heartbeat time.Duration
+
+	messages chan redis.Message // 启用缓冲区时，等待处理的消息
+	overflow string
+
+	mu           sync.Mutex // 保护以下字段，同时保证同一时间只有一个协程向连接写入命令
+	channels     map[string]bool
+	psc          *redis.PubSubConn
+	closed       bool
+	waiters      map[string][]chan struct{} // 等待服务器确认订阅的频道
+	onSubscribed func(channel string)
+}
+
+func newSubscription(c *Cacher, onMessage func(channel string, data []byte) error, channels ...string) *Subscription {
+	s := &Subscription{
+		c:         c,
+		onMessage: onMessage,
+		heartbeat: c.heartbeat,
+		channels:  make(map[string]bool),
+		waiters:   make(map[string][]chan struct{}),
+	}
+	for _, channel := range channels {
+		s.channels[channel] = true
+	}
+	if c.subscribeBuffer > 0 {
+		s.messages = make(chan redis.Message, c.subscribeBuffer)
+		s.overflow = c.subscribeOverflow
+		go s.handle()
+	}
+	return s
+}
+
+// Channels 返回当前订阅的所有频道
+func (s *Subscription) Channels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.channelList()
+}
+
+// Subscribe 在当前订阅中增加频道，服务器确认订阅后才返回，之后发布到这些频道的消息都能收到。
+// 连接在确认前断开时也会返回，返回错误时，频道仍会在自动重新连接后被订阅。
+func (s *Subscription) Subscribe(channels ...string) error {
+	s.mu.Lock()
+	for _, channel := range channels {
+		s.channels[channel] = true
+	}
+	if s.psc == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	waits := s.expect(channels)
+	err := s.psc.Subscribe(redis.Args{}.AddFlat(channels)...)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	wait(waits)
+	return nil
+}
+
+// OnSubscribed 设置服务器确认订阅频道时的回调，包括自动重新连接后重新订阅的确认。
+// 通过 Cacher.Subscribe 创建订阅时的确认在设置回调之前就已完成，不会触发回调。
+func (s *Subscription) OnSubscribed(fn func(channel string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSubscribed = fn
+}
+
+// expect 返回在服务器确认订阅频道后被关闭的通道，调用前必须持有锁
+func (s *Subscription) expect(channels []string) []chan struct{} {
+	waits := make([]chan struct{}, 0, len(channels))
+	for _, channel := range channels {
+		w := make(chan struct{})
+		s.waiters[channel] = append(s.waiters[channel], w)
+		waits = append(waits, w)
+	}
+	return waits
+}
+
+// confirm 通知等待频道订阅确认的调用方
+func (s *Subscription) confirm(channel string) {
+	s.mu.Lock()
+	for _, w := range s.waiters[channel] {
+		close(w)
+	}
+	delete(s.waiters, channel)
+	fn := s.onSubscribed
+	s.mu.Unlock()
+	if fn != nil {
+		fn(channel)
+	}
+}
+
+// releaseWaiters 连接断开时，结束所有调用方的等待，避免连接失效期间一直阻塞。频道会在重新连接后订阅
+func (s *Subscription) releaseWaiters() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for channel, waits := range s.waiters {
+		for _, w := range waits {
+			close(w)
+		}
+		delete(s.waiters, channel)
+	}
+}
+
+func wait(waits []chan struct{}) {
+	for _, w := range waits {
+		<-w
+	}
+}
+
+// Unsubscribe 取消订阅当前订阅中的频道
+func (s *Subscription) Unsubscribe(channels ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, channel := range channels {
+		delete(s.channels, channel)
+	}
+	if s.psc == nil {
+		return nil
+	}
+	return s.psc.Unsubscribe(redis.Args{}.AddFlat(channels)...)
+}
+
+// Close 取消所有订阅，服务器确认后关闭连接，之后不再自动重新连接
+func (s *Subscription) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.psc == nil {
+		return nil
+	}
+	// 连接由接收消息的协程在收到确认后关闭，避免与其同时读取连接
+	return s.psc.Unsubscribe()
+}
+
+// HeartbeatInterval 返回心跳的间隔，由 Options.SubscribeHeartbeat 设置，值为0时表示不发送心跳
+func (s *Subscription) HeartbeatInterval() time.Duration {
+	return s.heartbeat
+}
+
+// Dropped 返回启用 Options.SubscribeBuffer 时，因缓冲区已满而丢弃的消息数量
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Subscription) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// connect 获取新的连接并订阅当前所有的频道
+func (s *Subscription) connect() (redis.PubSubConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	psc := redis.PubSubConn{Conn: s.c.pool.Get()}
+	if s.closed {
+		psc.Close()
+		return psc, errSubscriptionClosed
+	}
+	if len(s.channels) > 0 {
+		if err := psc.Subscribe(redis.Args{}.AddFlat(s.channelList())...); err != nil {
+			psc.Close()
+			return psc, err
+		}
+	}
+	s.psc = &psc
+	atomic.AddInt32(s.c.subscriptions, 1)
+	return psc, nil
+}
+
+// run 接收并处理消息，连接异常时重新连接
+func (s *Subscription) run(psc redis.PubSubConn) {
+	if s.messages != nil {
+		defer close(s.messages)
+	}
+	for {
+		s.receive(psc)
+		s.releaseWaiters()
+		// 持有锁关闭连接，避免与发送心跳或订阅命令的协程同时使用连接
+		s.mu.Lock()
+		psc.Close()
+		s.psc = nil
+		atomic.AddInt32(s.c.subscriptions, -1)
+		s.mu.Unlock()
+		for {
+			if s.isClosed() {
+				return
+			}
+			time.Sleep(time.Second)
+			var err error
+			psc, err = s.connect()
+			if err == errSubscriptionClosed {
+				return
+			}
+			if err == nil {
+				break
+			}
+			s.c.logger.Printf("resubscribe: %v", err)
+		}
+	}
+}
+
+// receive 处理消息，直到连接出错、心跳超时或订阅被关闭
+func (s *Subscription) receive(psc redis.PubSubConn) {
+	if s.heartbeat > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go s.ping(psc, done)
+	}
+	for {
+		var reply interface{}
+		if s.heartbeat > 0 {
+			// 每个间隔都会发送PING，超过两个间隔没有收到任何回复说明连接已经失效
+			reply = psc.ReceiveWithTimeout(2 * s.heartbeat)
+		} else {
+			reply = psc.Receive()
+		}
+		switch v := reply.(type) {
+		case redis.Message:
+			s.deliver(v)
+		case redis.Subscription:
+			if v.Kind == "subscribe" {
+				s.confirm(v.Channel)
+			}
+			if v.Count == 0 && s.isClosed() {
+				return
+			}
+		case error:
+			if !s.isClosed() {
+				s.c.logger.Printf("subscription: %v", v)
+			}
+			return
+		}
+	}
+}
+
+// deliver 将消息交给 onMessage 处理，启用缓冲区时按 overflow 策略放入缓冲区
+func (s *Subscription) deliver(msg redis.Message) {
+	if s.messages == nil {
+		go s.onMessage(msg.Channel, msg.Data)
+		return
+	}
+	switch s.overflow {
+	case "drop-newest":
+		select {
+		case s.messages <- msg:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	case "drop-oldest":
+		for {
+			select {
+			case s.messages <- msg:
+				return
+			default:
+			}
+			select {
+			case <-s.messages:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	default:
+		s.messages <- msg
+	}
+}
+
+// handle 按顺序处理缓冲区中的消息，直到缓冲区被关闭
+func (s *Subscription) handle() {
+	for msg := range s.messages {
+		s.onMessage(msg.Channel, msg.Data)
+	}
+}
+
+// ping 定期发送心跳，直到 done 被关闭
+func (s *Subscription) ping(psc redis.PubSubConn, done chan struct{}) {
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			err := psc.Ping("")
+			s.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Subscription) channelList() []string {
+	channels := make([]string, 0, len(s.channels))
+	for channel := range s.channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
@@ -0,0 +1,191 @@
+package redisgo
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// trackingMaxAge 客户端缓存中值的最长保留时间。正常情况下值在被修改时由失效通知删除，此处只是防止通知丢失时长期返回旧值
+const trackingMaxAge = 10 * time.Minute
+
+// tracker 基于 CLIENT TRACKING 的客户端缓存。
+// 由于 redigo 只支持RESP2协议，使用重定向模式：一个专用的连接订阅 __redis__:invalidate 频道，
+// 读取键值的连接通过 CLIENT TRACKING ON REDIRECT 将失效通知发送到该连接。
+// 读取键值的连接也是专用的，不放回连接池：跟踪会一直开启，关闭跟踪后 redis 不再发送之前读取的键的失效通知。
+type tracker struct {
+	cache *localCache
+
+	mu  sync.Mutex // 保证失效通知与写入缓存互斥，避免写入已经失效的值
+	id  int64      // 订阅失效通知的连接的ID，为0时表示未连接，此时不使用缓存
+	seq uint64     // 收到失效通知的次数
+
+	connMu   sync.Mutex // 保护读取键值的连接，缓存未命中的读取依次执行
+	conn     redis.Conn // 读取键值的连接，为 nil 时在下次读取时建立
+	redirect int64      // conn 开启跟踪时重定向到的连接ID
+}
+
+// closeConn 关闭读取键值的连接
+func (t *tracker) closeConn() {
+	t.connMu.Lock()
+	defer t.connMu.Unlock()
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
+
+// invalidate 删除失效的键，keys 为空时清空缓存
+func (t *tracker) invalidate(keys []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	if len(keys) == 0 {
+		t.cache.clear()
+		return
+	}
+	for _, key := range keys {
+		t.cache.del(key)
+	}
+}
+
+// setID 设置订阅失效通知的连接的ID，连接变化期间可能丢失通知，所以同时清空缓存
+func (t *tracker) setID(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	t.id = id
+	t.cache.clear()
+}
+
+// GetTracked 获取键值并按照 Decode 的方式写入 dest ，键不存在时返回 ErrNil 。
+// 设置了 TrackingCacheSize 时，读取过的值保存在进程内缓存中，之后直接从缓存返回，键被任何客户端修改或过期后，
+// redis 通过 CLIENT TRACKING 发送失效通知，缓存随即被删除。适用于读多写少的热点键，需要redis 6.0及以上版本。
+// 未启用或失效通知的连接断开时，直接从redis读取。集群模式下不支持 TrackingCacheSize 。
+func (c *Cacher) GetTracked(key string, dest interface{}) error {
+	t := c.tracker
+	if t == nil {
+		return c.GetObject(key, dest)
+	}
+	fullKey := c.getKey(key)
+	if value, ok := t.cache.get(fullKey); ok {
+		return c.Decode([]byte(value), dest)
+	}
+	t.mu.Lock()
+	id, seq := t.id, t.seq
+	t.mu.Unlock()
+	if id == 0 {
+		return c.GetObject(key, dest)
+	}
+
+	reply, err := c.readTracked(fullKey, id)
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return ErrNil
+	}
+	value, err := redis.String(reply, nil)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	if t.id == id && t.seq == seq {
+		t.cache.set(fullKey, value, trackingMaxAge)
+	}
+	t.mu.Unlock()
+	return c.Decode(reply, dest)
+}
+
+// readTracked 在开启了跟踪的专用连接上读取键值，失效通知重定向到ID为 id 的连接。
+// 连接出错或订阅失效通知的连接变化后重新建立。
+func (c *Cacher) readTracked(key string, id int64) (interface{}, error) {
+	t := c.tracker
+	t.connMu.Lock()
+	defer t.connMu.Unlock()
+	if t.conn != nil && (t.conn.Err() != nil || t.redirect != id) {
+		t.conn.Close()
+		t.conn = nil
+	}
+	if t.conn == nil {
+		conn, err := c.pool.Dial()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := conn.Do("CLIENT", "TRACKING", "ON", "REDIRECT", id); err != nil {
+			conn.Close()
+			return nil, wrapError(err)
+		}
+		t.conn, t.redirect = conn, id
+	}
+	reply, err := t.conn.Do("GET", key)
+	return reply, wrapError(err)
+}
+
+// runTracker 订阅失效通知，连接异常时重新连接，直到调用 Shutdown
+func (c *Cacher) runTracker() {
+	for {
+		if err := c.receiveInvalidations(); err != nil {
+			c.logger.Printf("client tracking: %v", err)
+		}
+		c.tracker.setID(0)
+		select {
+		case <-c.closing:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// receiveInvalidations 建立订阅失效通知的连接并处理通知，直到连接出错或调用 Shutdown
+func (c *Cacher) receiveInvalidations() error {
+	conn := c.pool.Get()
+	defer conn.Close()
+	atomic.AddInt32(c.subscriptions, 1)
+	defer atomic.AddInt32(c.subscriptions, -1)
+
+	id, err := redis.Int64(conn.Do("CLIENT", "ID"))
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Do("SUBSCRIBE", "__redis__:invalidate"); err != nil {
+		return err
+	}
+	c.tracker.setID(id)
+
+	// 调用 Shutdown 后取消订阅，使下面的接收结束
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	defer wg.Wait()
+	defer close(done)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-c.closing:
+			conn.Send("UNSUBSCRIBE")
+			conn.Flush()
+		case <-done:
+		}
+	}()
+	for {
+		reply, err := conn.Receive()
+		if err != nil {
+			return err
+		}
+		values, ok := reply.([]interface{})
+		if !ok || len(values) != 3 {
+			continue
+		}
+		switch kind, _ := redis.String(values[0], nil); kind {
+		case "unsubscribe":
+			return nil
+		case "message":
+			// 值为 nil 时表示执行了 FLUSHALL 等命令，所有的键都已失效
+			keys, _ := redis.Strings(values[2], nil)
+			c.tracker.invalidate(keys)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package redisgo
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var errBadVersionedValue = errors.New("redisgo: value was not saved by SetVersioned")
+
+// BumpVersion 将版本键 key 的值加1并返回新的版本号。
+// 数据源发生变化时调用，之前通过 SetVersioned 保存的、依赖该版本键的缓存在 GetVersioned 时都会被判定为过期，不需要逐个删除。
+// Example:
+//
+// ```golang
+// err := c.SetVersioned("user:1:feed", "user:1:version", feed, 600)
+// // 用户数据变化后
+// _, err = c.BumpVersion("user:1:version")
+// stale, err := c.GetVersioned("user:1:feed", "user:1:version", &feed)
+// ```
+func (c *Cacher) BumpVersion(key string) (int64, error) {
+	return Int64(c.Do("INCR", c.getKey(key)))
+}
+
+// SetVersioned 保存键值并设置有效时长，同时在值中记录版本键 versionKey 的当前版本，版本键不存在时版本为0。
+// 值的序列化方式与 Set 相同。集群模式下两个键必须在同一个哈希槽中，参见 HashTag 。
+func (c *Cacher) SetVersioned(dataKey, versionKey string, val interface{}, expire int64) error {
+	version, err := c.currentVersion(versionKey)
+	if err != nil {
+		return err
+	}
+	value, err := c.encode(val)
+	if err != nil {
+		return err
+	}
+	payload := strconv.FormatInt(version, 10) + ":" + string(encodedReply(value))
+	return c.Set(dataKey, payload, expire)
+}
+
+// GetVersioned 读取通过 SetVersioned 保存的键值并按照 Decode 的方式写入 dest ，键不存在时返回 ErrNil 。
+// 值中记录的版本与版本键 versionKey 的当前版本不同时 stale 为 true ，此时 dest 仍会被写入旧的值，由调用方决定是否重新生成。
+// 两个键通过 MGET 在一次往返中读取，集群模式下必须在同一个哈希槽中。
+func (c *Cacher) GetVersioned(dataKey, versionKey string, dest interface{}) (stale bool, err error) {
+	values, err := redis.Values(c.Do("MGET", c.getKey(dataKey), c.getKey(versionKey)))
+	if err != nil {
+		return false, err
+	}
+	if len(values) != 2 {
+		return false, errors.New("redisgo: unexpected number of values")
+	}
+	payload, err := redis.Bytes(values[0], nil)
+	if err != nil {
+		return false, err
+	}
+	current := int64(0)
+	if values[1] != nil {
+		if current, err = redis.Int64(values[1], nil); err != nil {
+			return false, err
+		}
+	}
+	i := bytes.IndexByte(payload, ':')
+	if i < 0 {
+		return false, errBadVersionedValue
+	}
+	version, err := strconv.ParseInt(string(payload[:i]), 10, 64)
+	if err != nil {
+		return false, errBadVersionedValue
+	}
+	if err := c.Decode(payload[i+1:], dest); err != nil {
+		return false, err
+	}
+	return version != current, nil
+}
+
+// currentVersion 返回版本键的当前版本，不存在时为0
+func (c *Cacher) currentVersion(key string) (int64, error) {
+	version, err := Int64(c.Do("GET", c.getKey(key)))
+	if err == ErrNil {
+		return 0, nil
+	}
+	return version, err
+}